@@ -2,29 +2,42 @@ package liqu
 
 type (
 	branch struct {
-		liqu             *Liqu
-		root             *branch
-		parent           *branch
-		isCTE            bool
-		slice            bool
-		anonymous        bool
-		as               string
-		name             string
-		where            *ConditionBuilder
-		isSearched       bool
-		order            *OrderBuilder
-		groupBy          *GroupByBuilder
-		source           Source
-		limit            *int
-		offset           *int
-		registry         *registry
-		branches         []*branch
-		relations        []branchRelation
-		selectedFields   []string
-		aggregateFields  []aggregateField
-		distinctFields   map[string]bool
-		referencedFields map[string]bool
-		subQuery         map[string]*SubQuery
+		liqu      *Liqu
+		root      *branch
+		parent    *branch
+		isCTE     bool
+		slice     bool
+		anonymous bool
+		as        string
+		key       string
+		name      string
+		where     *ConditionBuilder
+		// outerWhere holds the conditions the wrapping query applies on the columns of the root, like the ones on window fields
+		outerWhere      *ConditionBuilder
+		having          *ConditionBuilder
+		isSearched      bool
+		excluded        bool
+		children        ChildrenMode
+		order           *OrderBuilder
+		groupBy         *GroupByBuilder
+		source          Source
+		limit           *int
+		offset          *int
+		registry        *registry
+		branches        []*branch
+		relations       []branchRelation
+		selectedFields  []string
+		orderedFields   []string
+		aggregateFields []aggregateField
+		aggregateGroups []string
+		// relationAggregates are the fields of the root computed over a relation
+		relationAggregates []relationAggregate
+		report             bool
+		reportColumns      []reportColumn
+		distinctFields     map[string]bool
+		distinctOn         []string
+		referencedFields   map[string]bool
+		subQuery           map[string]*SubQuery
 
 		joinDirection string
 		joinFields    []branchJoinField
@@ -34,6 +47,7 @@ type (
 	branchJoinField struct {
 		table string
 		field string
+		key   string
 		as    string
 		cte   bool
 		slice bool
@@ -62,6 +76,9 @@ type (
 	}
 
 	joinOperator string
+
+	// ChildrenMode decides what a search on a slice relation does to the children that are returned.
+	ChildrenMode string
 )
 
 const (
@@ -69,3 +86,57 @@ const (
 	InnerJoin              = "INNER"
 	fullJoin               = "FULL"
 )
+
+const (
+	// ChildrenMatching narrows the children to the ones matching the search, this is the default.
+	ChildrenMatching ChildrenMode = "matching"
+	// ChildrenAll filters the parents on the search, but returns all of their children.
+	ChildrenAll ChildrenMode = "all"
+)
+
+// valid reports whether the mode is known, an empty mode falls back on the default.
+func (m ChildrenMode) valid() bool {
+	switch m {
+	case "", ChildrenMatching, ChildrenAll:
+		return true
+	}
+
+	return false
+}
+
+// childrenMode returns the mode of the relation, falling back on the mode of the request
+func (l *Liqu) childrenMode(branch *branch) ChildrenMode {
+	if branch.children != "" {
+		return branch.children
+	}
+
+	if l.children != "" {
+		return l.children
+	}
+
+	return ChildrenMatching
+}
+
+// searched reports whether the branch or one of its relations is searched on
+func (b *branch) searched() bool {
+	if b.isSearched {
+		return true
+	}
+
+	for _, v := range b.branches {
+		if v.searched() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonKey returns the key the branch is returned under, the name in the json tag of its field or its field name.
+func (b *branch) jsonKey() string {
+	if b.key != "" && b.key != "-" {
+		return b.key
+	}
+
+	return b.as
+}
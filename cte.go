@@ -40,8 +40,12 @@ func (l *Liqu) WithCte(as string, cte *Cte) *Liqu {
 	l.cte[as] = cte
 
 	l.registry[as] = registry{
-		fieldDatabase: cte.fieldDatabase,
-		tableName:     cte.baseTable,
+		fieldDatabase:   cte.fieldDatabase,
+		fieldNormalize:  make(map[string]normalize),
+		fieldExpression: make(map[string]string),
+		fieldWindow:     make(map[string]bool),
+		fieldJSON:       make(map[string]string),
+		tableName:       cte.baseTable,
 		branch: &branch{
 			isCTE:           true,
 			selectedFields:  make([]string, 0),
@@ -13,6 +13,10 @@ type (
 )
 
 func (l *Liqu) traverseCteBranch(branch *branch, parent *branch) (string, error) {
+	if _, _, err := l.distinctOn(branch); err != nil {
+		return "", err
+	}
+
 	baseCTE := newBaseQuery().setFrom(branch.registry.tableName)
 
 	baseJoin := newBaseQuery().setFrom(branch.as)
@@ -91,6 +95,7 @@ func (l *Liqu) traverseCteBranch(branch *branch, parent *branch) (string, error)
 	parent.joinFields = append(parent.joinFields, branchJoinField{
 		table: branch.source.Table(),
 		field: branch.as,
+		key:   branch.jsonKey(),
 		as:    branch.as,
 		cte:   true,
 		slice: branch.slice,
@@ -44,7 +44,7 @@ func TestCte(t *testing.T) {
 
 	sqlQuery, sqlParams := li.SQL()
 
-	expected := `WITH "TagSearch" AS ( SELECT project_advisor.id_project FROM "tag" LEFT JOIN project_tag ON project_tag.id_tag = tag.id WHERE "tag"."name" ~~* $2 ) SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project" FROM ( SELECT "project"."id" AS "ID", "project"."company_id" AS "CompanyID" FROM "project" WHERE "project"."company_id" = $1 AND "project"."id" IN (SELECT * FROM "TagSearch") GROUP BY "project"."id", "project"."company_id" ) AS "Project" LIMIT 25 OFFSET 0 ) q`
+	expected := `WITH "TagSearch" AS ( SELECT project_advisor.id_project FROM "tag" LEFT JOIN project_tag ON project_tag.id_tag = tag.id WHERE "tag"."name" ~~* $2 ) SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project" FROM ( SELECT "project"."id" AS "ID", "project"."company_id" AS "CompanyID" FROM "project" WHERE "project"."company_id" = $1 AND "project"."id" IN (SELECT * FROM "TagSearch") GROUP BY "project"."id", "project"."company_id" ORDER BY "project"."id" ASC) AS "Project" ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
 
 	if sqlQuery != expected {
 		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
@@ -0,0 +1,380 @@
+package liqu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	contextKey string
+
+	// dateRange is the resolved form of a date value. a point in time has an equal from and to,
+	// a calendar period runs from `from` up to, but not including, `to`.
+	dateRange struct {
+		from time.Time
+		to   time.Time
+	}
+)
+
+const timezoneKey contextKey = "liqu-timezone"
+
+var (
+	// nowFunc is used to resolve relative dates, it can be replaced in tests.
+	nowFunc = time.Now
+
+	relativeDateRegex = regexp.MustCompile(`^([a-zA-Z]+)(?:([+-])([0-9]+)([smhdwMy]))?$`)
+	quarterRegex      = regexp.MustCompile(`^([0-9]{4})-Q([1-4])$`)
+	yearRegex         = regexp.MustCompile(`^[0-9]{4}$`)
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// ContextWithTimezone returns a copy of ctx that carries the location used to resolve relative dates
+// and to compare timestamps by calendar day with the Date operator. the name of the location is passed to the
+// database, so it has to be loaded by its IANA name, time.Local is named "Local" and is rejected.
+func ContextWithTimezone(ctx context.Context, loc *time.Location) (context.Context, error) {
+	if loc == nil || loc == time.Local {
+		return ctx, errors.New("[liqu] timezone requires a location loaded by its IANA name, like time.LoadLocation(\"Europe/Amsterdam\")")
+	}
+
+	return context.WithValue(ctx, timezoneKey, loc), nil
+}
+
+func timezoneFromContext(ctx context.Context) *time.Location {
+	if ctx == nil {
+		return time.UTC
+	}
+
+	if loc, ok := ctx.Value(timezoneKey).(*time.Location); ok && loc != nil {
+		return loc
+	}
+
+	return time.UTC
+}
+
+func (r dateRange) point() bool {
+	return r.from.Equal(r.to)
+}
+
+// day widens the range to the calendar days it touches.
+func (r dateRange) day() dateRange {
+	from := startOfDay(r.from)
+	to := startOfDay(r.to)
+	if r.point() || !to.Equal(r.to) {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	return dateRange{from: from, to: to}
+}
+
+// resolveDate turns values like `now-7d`, `today`, `startOfMonth`, `2024-Q1` or `2024-03-05` into a concrete
+// point or period. values that are not recognised are left to the database to interpret.
+func resolveDate(value string, now time.Time, loc *time.Location) (dateRange, bool) {
+	value = strings.TrimSpace(value)
+
+	if r, ok := resolveAbsoluteDate(value, loc); ok {
+		return r, true
+	}
+
+	match := relativeDateRegex.FindStringSubmatch(value)
+	if match == nil {
+		return dateRange{}, false
+	}
+
+	var (
+		r     dateRange
+		today = startOfDay(now)
+	)
+
+	switch match[1] {
+	case "now":
+		r = dateRange{from: now, to: now}
+	case "today":
+		r = dateRange{from: today, to: today.AddDate(0, 0, 1)}
+	case "yesterday":
+		r = dateRange{from: today.AddDate(0, 0, -1), to: today}
+	case "tomorrow":
+		r = dateRange{from: today.AddDate(0, 0, 1), to: today.AddDate(0, 0, 2)}
+	case "startOfDay":
+		r = dateRange{from: today, to: today}
+	case "startOfWeek":
+		week := startOfWeek(now)
+		r = dateRange{from: week, to: week}
+	case "startOfMonth":
+		month := startOfMonth(now)
+		r = dateRange{from: month, to: month}
+	case "startOfQuarter":
+		quarter := startOfQuarter(now)
+		r = dateRange{from: quarter, to: quarter}
+	case "startOfYear":
+		year := startOfYear(now)
+		r = dateRange{from: year, to: year}
+	case "thisWeek":
+		week := startOfWeek(now)
+		r = dateRange{from: week, to: week.AddDate(0, 0, 7)}
+	case "thisMonth":
+		month := startOfMonth(now)
+		r = dateRange{from: month, to: month.AddDate(0, 1, 0)}
+	case "thisQuarter":
+		quarter := startOfQuarter(now)
+		r = dateRange{from: quarter, to: quarter.AddDate(0, 3, 0)}
+	case "thisYear":
+		year := startOfYear(now)
+		r = dateRange{from: year, to: year.AddDate(1, 0, 0)}
+	default:
+		return dateRange{}, false
+	}
+
+	if match[2] != "" {
+		n, err := strconv.Atoi(match[3])
+		if err != nil {
+			return dateRange{}, false
+		}
+
+		if match[2] == "-" {
+			n = -n
+		}
+
+		r.from = shiftDate(r.from, n, match[4])
+		r.to = shiftDate(r.to, n, match[4])
+	}
+
+	return r, true
+}
+
+func resolveAbsoluteDate(value string, loc *time.Location) (dateRange, bool) {
+	if match := quarterRegex.FindStringSubmatch(value); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		quarter, _ := strconv.Atoi(match[2])
+
+		from := time.Date(year, time.Month((quarter-1)*3+1), 1, 0, 0, 0, 0, loc)
+		return dateRange{from: from, to: from.AddDate(0, 3, 0)}, true
+	}
+
+	if yearRegex.MatchString(value) {
+		year, _ := strconv.Atoi(value)
+
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+		return dateRange{from: from, to: from.AddDate(1, 0, 0)}, true
+	}
+
+	if from, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return dateRange{from: from, to: from.AddDate(0, 0, 1)}, true
+	}
+
+	if from, err := time.ParseInLocation("2006-01", value, loc); err == nil {
+		return dateRange{from: from, to: from.AddDate(0, 1, 0)}, true
+	}
+
+	return dateRange{}, false
+}
+
+func shiftDate(t time.Time, n int, unit string) time.Time {
+	switch unit {
+	case "s":
+		return t.Add(time.Duration(n) * time.Second)
+	case "m":
+		return t.Add(time.Duration(n) * time.Minute)
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour)
+	case "d":
+		return t.AddDate(0, 0, n)
+	case "w":
+		return t.AddDate(0, 0, n*7)
+	case "M":
+		return t.AddDate(0, n, 0)
+	case "y":
+		return t.AddDate(n, 0, 0)
+	}
+
+	return t
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns the monday of the week t is in.
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func startOfQuarter(t time.Time) time.Time {
+	return time.Date(t.Year(), ((t.Month()-1)/3)*3+1, 1, 0, 0, 0, 0, t.Location())
+}
+
+func startOfYear(t time.Time) time.Time {
+	return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+}
+
+func isTimeType(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t == timeType
+}
+
+// dateCondition adds a condition on a timestamp column where the value(s) resolve to dates. periods are compared
+// as half open ranges, so `Date|=|2024-Q1` matches the whole quarter. it reports false when the value could not be
+// resolved and the condition should be handled as a regular one.
+func (l *Liqu) dateCondition(cb *ConditionBuilder, outer Operator, column string, op Operator, val interface{}) (bool, error) {
+	var values []string
+	switch v := val.(type) {
+	case string:
+		values = []string{v}
+	case []string:
+		values = v
+	default:
+		return false, nil
+	}
+
+	if len(values) == 0 || (op == Between && len(values) != 2) {
+		return false, nil
+	}
+
+	if op == Date && len(values) > 2 {
+		return true, fmt.Errorf("invalid date value %s", strings.Join(values, "--"))
+	}
+
+	var (
+		loc    = timezoneFromContext(l.ctx)
+		now    = nowFunc().In(loc)
+		ranges = make([]dateRange, len(values))
+	)
+
+	for i, v := range values {
+		r, ok := resolveDate(v, now, loc)
+		if !ok {
+			if op == Date {
+				return true, fmt.Errorf("invalid date value %s", v)
+			}
+
+			return false, nil
+		}
+
+		ranges[i] = r
+	}
+
+	bound := func(t time.Time) interface{} {
+		return t
+	}
+
+	if op == Date {
+		// compare on the calendar day as seen from the requested timezone
+		column = fmt.Sprintf("(%s AT TIME ZONE %s)::date", column, cb.bind(loc.String()))
+		bound = func(t time.Time) interface{} {
+			return t.Format("2006-01-02")
+		}
+
+		for i := range ranges {
+			ranges[i] = ranges[i].day()
+
+			// a single day is compared as is
+			if ranges[i].to.Equal(ranges[i].from.AddDate(0, 0, 1)) {
+				ranges[i].to = ranges[i].from
+			}
+		}
+
+		op = Equal
+		if len(ranges) == 2 {
+			op = Between
+		}
+	}
+
+	if len(ranges) > 1 && op != Between {
+		return false, nil
+	}
+
+	var (
+		first = ranges[0]
+		last  = ranges[len(ranges)-1]
+	)
+
+	single := func(op Operator, t time.Time) {
+		if outer == And {
+			cb.And(column, op, bound(t))
+		} else {
+			cb.Or(column, op, bound(t))
+		}
+	}
+
+	nested := func(fn func(*ConditionBuilder)) {
+		group := NewConditionBuilder().setCounter(cb.counter).setLiqu(cb.liqu)
+		fn(group)
+
+		if len(cb.conditions) > 0 {
+			cb.conditions = append(cb.conditions, outer.String())
+		}
+
+		cb.conditions = append(cb.conditions, fmt.Sprintf("(%s)", group.Build()))
+		cb.args = append(cb.args, group.Args()...)
+		cb.setCounter(group.counter)
+	}
+
+	switch op {
+	case Equal:
+		if first.point() {
+			single(Equal, first.from)
+			break
+		}
+
+		nested(func(n *ConditionBuilder) {
+			n.And(column, GreaterThanOrEqual, bound(first.from)).And(column, LessThan, bound(first.to))
+		})
+	case NotEqual:
+		if first.point() {
+			single(NotEqual, first.from)
+			break
+		}
+
+		nested(func(n *ConditionBuilder) {
+			n.Or(column, LessThan, bound(first.from)).Or(column, GreaterThanOrEqual, bound(first.to))
+		})
+	case GreaterThan:
+		if first.point() {
+			single(GreaterThan, first.from)
+			break
+		}
+
+		single(GreaterThanOrEqual, first.to)
+	case GreaterThanOrEqual:
+		single(GreaterThanOrEqual, first.from)
+	case LessThan:
+		single(LessThan, first.from)
+	case LessThanOrEqual:
+		if first.point() {
+			single(LessThanOrEqual, first.from)
+			break
+		}
+
+		single(LessThan, first.to)
+	case Between:
+		nested(func(n *ConditionBuilder) {
+			n.And(column, GreaterThanOrEqual, bound(first.from))
+			if last.point() {
+				n.And(column, LessThanOrEqual, bound(last.from))
+			} else {
+				n.And(column, LessThan, bound(last.to))
+			}
+		})
+	default:
+		return false, nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,136 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type (
+	Event struct {
+		ID      int       `db:"id"`
+		Title   string    `db:"title"`
+		StartAt time.Time `db:"start_at"`
+	}
+
+	EventList struct {
+		Event Event
+	}
+)
+
+func (m *Event) Table() string {
+	return "event"
+}
+
+func (m *Event) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
+func TestResolveDate(t *testing.T) {
+	now := time.Date(2024, time.May, 15, 13, 30, 0, 0, time.UTC)
+
+	test := []struct {
+		Value string
+		From  time.Time
+		To    time.Time
+	}{
+		{Value: "now", From: now, To: now},
+		{Value: "now-7d", From: now.AddDate(0, 0, -7), To: now.AddDate(0, 0, -7)},
+		{Value: "now+2h", From: now.Add(2 * time.Hour), To: now.Add(2 * time.Hour)},
+		{Value: "today", From: time.Date(2024, time.May, 15, 0, 0, 0, 0, time.UTC), To: time.Date(2024, time.May, 16, 0, 0, 0, 0, time.UTC)},
+		{Value: "yesterday", From: time.Date(2024, time.May, 14, 0, 0, 0, 0, time.UTC), To: time.Date(2024, time.May, 15, 0, 0, 0, 0, time.UTC)},
+		{Value: "startOfWeek", From: time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC), To: time.Date(2024, time.May, 13, 0, 0, 0, 0, time.UTC)},
+		{Value: "startOfMonth", From: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)},
+		{Value: "thisMonth-1M", From: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)},
+		{Value: "2024-Q1", From: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{Value: "2023", From: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{Value: "2024-02", From: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{Value: "2024-03-05", From: time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC), To: time.Date(2024, time.March, 6, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, te := range test {
+		r, ok := resolveDate(te.Value, now, time.UTC)
+		if !ok {
+			t.Errorf("expected %s to resolve", te.Value)
+			continue
+		}
+
+		if !r.from.Equal(te.From) || !r.to.Equal(te.To) {
+			t.Errorf("%s: expected:\n%s - %s\ngot:\n%s - %s", te.Value, te.From, te.To, r.from, r.to)
+		}
+	}
+
+	if _, ok := resolveDate("2024-05-15T10:00:00Z", now, time.UTC); ok {
+		t.Errorf("expected timestamps to be left untouched")
+	}
+}
+
+func TestWhereRelativeDate(t *testing.T) {
+	nowFunc = func() time.Time {
+		return time.Date(2024, time.May, 15, 13, 30, 0, 0, time.UTC)
+	}
+	defer func() {
+		nowFunc = time.Now
+	}()
+
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	filters := &Filters{
+		Select: "Event.ID",
+		Where:  "Event.StartAt|=|2024-Q1,Event.StartAt|>=|now-30d,Event.StartAt|date|today",
+	}
+
+	ctx, err := ContextWithTimezone(context.TODO(), amsterdam)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	li := New(ctx, filters)
+
+	err = li.FromSource(make([]EventList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Event" ) AS "Event" FROM ( SELECT "event"."id" AS "ID" FROM "event" WHERE ("event"."start_at" >= $1 AND "event"."start_at" < $2) AND "event"."start_at" >= $3 AND ("event"."start_at" AT TIME ZONE $4)::date = $5 GROUP BY "event"."id" ORDER BY "event"."id" ASC) AS "Event" ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 5 {
+		t.Fatalf("expected 5 params, got %d", len(sqlParams))
+	}
+
+	if from, ok := sqlParams[0].(time.Time); !ok || !from.Equal(time.Date(2024, time.January, 1, 0, 0, 0, 0, amsterdam)) {
+		t.Errorf("expected start of the quarter, got %v", sqlParams[0])
+	}
+
+	if sqlParams[3] != "Europe/Amsterdam" {
+		t.Errorf("expected Europe/Amsterdam, got %v", sqlParams[3])
+	}
+
+	if sqlParams[4] != "2024-05-15" {
+		t.Errorf("expected 2024-05-15, got %v", sqlParams[4])
+	}
+}
+
+func TestContextWithTimezone(t *testing.T) {
+	if _, err := ContextWithTimezone(context.TODO(), time.Local); err == nil {
+		t.Error("expected an error for time.Local, which has no name the database knows")
+	}
+
+	if _, err := ContextWithTimezone(context.TODO(), nil); err == nil {
+		t.Error("expected an error without a location")
+	}
+
+	ctx, err := ContextWithTimezone(context.TODO(), time.UTC)
+	if err != nil || timezoneFromContext(ctx) != time.UTC {
+		t.Errorf("expected UTC to be accepted, got %v", err)
+	}
+}
@@ -1,11 +1,41 @@
 package liqu
 
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// OrderAppend appends the order of the user to the default order, this is the default.
+	OrderAppend OrderMerge = "append"
+	// OrderPrepend puts the order of the user in front of the default order.
+	OrderPrepend OrderMerge = "prepend"
+	// OrderReplace only uses the default order when the user did not provide one.
+	OrderReplace OrderMerge = "replace"
+)
+
 type (
+	// OrderMerge decides how the order of the user is combined with the default order.
+	OrderMerge string
+
 	Defaults struct {
-		where       map[string]defaultWhere
-		orderBy     map[string]OrderDirection
+		where       []defaultWhere
+		orderBy     []defaultOrder
+		orderMerge  OrderMerge
 		sel         map[string][]string
 		aggregation map[string][]aggregateField
+		normalize   map[string]normalize
+		distinctOn  map[string][]string
+		window      map[string]string
+
+		allowAggregate map[string][]Aggregator
+		allowGroupBy   map[string]bool
+	}
+
+	defaultOrder struct {
+		column    string
+		direction OrderDirection
+		nulls     OrderNulls
 	}
 
 	defaultWhere struct {
@@ -17,25 +47,89 @@ type (
 
 func NewDefaults() *Defaults {
 	return &Defaults{
-		where:       make(map[string]defaultWhere),
-		orderBy:     make(map[string]OrderDirection),
+		where:       make([]defaultWhere, 0),
+		orderBy:     make([]defaultOrder, 0),
+		orderMerge:  OrderAppend,
 		sel:         make(map[string][]string),
 		aggregation: make(map[string][]aggregateField),
+		normalize:   make(map[string]normalize),
+		distinctOn:  make(map[string][]string),
+		window:      make(map[string]string),
+
+		allowAggregate: make(map[string][]Aggregator),
+		allowGroupBy:   make(map[string]bool),
 	}
 }
 
+// OrderBy adds a default order, the orders take priority in the order they are added.
 func (d *Defaults) OrderBy(column string, direction OrderDirection) *Defaults {
-	d.orderBy[column] = direction
+	return d.order(defaultOrder{
+		column:    column,
+		direction: direction,
+	})
+}
+
+// OrderByNulls orders on the column with the NULL values placed first or last
+func (d *Defaults) OrderByNulls(column string, direction OrderDirection, nulls OrderNulls) *Defaults {
+	return d.order(defaultOrder{
+		column:    column,
+		direction: direction,
+		nulls:     nulls,
+	})
+}
+
+// OrderByMerge sets how the order_by of the user is combined with the default order.
+func (d *Defaults) OrderByMerge(mode OrderMerge) *Defaults {
+	d.orderMerge = mode
+
+	return d
+}
+
+// order adds the order, ordering on a column again replaces its earlier order while keeping its priority.
+func (d *Defaults) order(order defaultOrder) *Defaults {
+	for k, v := range d.orderBy {
+		if v.column == order.column {
+			d.orderBy[k] = order
+			return d
+		}
+	}
+
+	d.orderBy = append(d.orderBy, order)
 
 	return d
 }
 
+// modifiers returns the order modifiers as they appear in the order grammar
+func (o defaultOrder) modifiers() []string {
+	if o.nulls == "" {
+		return nil
+	}
+
+	return []string{o.nulls.modifier()}
+}
+
+// Where adds a default condition, which the user can not override. a column can have multiple conditions.
 func (d *Defaults) Where(column string, op Operator, value interface{}) *Defaults {
-	d.where[column] = defaultWhere{
+	d.where = append(d.where, defaultWhere{
 		column: column,
 		op:     op,
 		val:    value,
-	}
+	})
+
+	return d
+}
+
+// Unaccent compares and orders the column accent- and case-insensitive by wrapping both the column
+// and the value in unaccent(lower(...)). this requires the unaccent extension.
+func (d *Defaults) Unaccent(column string) *Defaults {
+	d.normalize[column] = d.normalize[column].merge(normalize{unaccent: true})
+
+	return d
+}
+
+// Collate applies the collation, for instance a nondeterministic ICU collation, when comparing and ordering the column.
+func (d *Defaults) Collate(column string, collation string) *Defaults {
+	d.normalize[column] = d.normalize[column].merge(normalize{collation: collation})
 
 	return d
 }
@@ -50,6 +144,43 @@ func (d *Defaults) Select(model string, fields ...string) *Defaults {
 	return d
 }
 
+// DistinctOn returns one row per distinct value of the fields of the root or of a slice relation,
+// the first row in the order of the model, like the latest price of every product.
+func (d *Defaults) DistinctOn(model string, fields ...string) *Defaults {
+	d.distinctOn[model] = append(d.distinctOn[model], fields...)
+
+	return d
+}
+
+// Window selects the field of the root computed with a window function, like `rank() OVER (ORDER BY {Score} DESC)`,
+// fields are referenced between braces. it is computed over all rows matching the conditions, before paginating.
+func (d *Defaults) Window(column, expression string) *Defaults {
+	d.window[column] = expression
+
+	return d
+}
+
+// AllowAggregate allows the functions to be requested on the column through the agg parameter,
+// all functions are allowed when none are given. nothing can be aggregated on request by default.
+func (d *Defaults) AllowAggregate(column string, funcs ...Aggregator) *Defaults {
+	if len(funcs) == 0 {
+		funcs = []Aggregator{AggCount, AggSum, AggAvg, AggMin, AggMax}
+	}
+
+	d.allowAggregate[column] = append(d.allowAggregate[column], funcs...)
+
+	return d
+}
+
+// AllowGroupBy allows the columns to be grouped on through the group_by parameter.
+func (d *Defaults) AllowGroupBy(columns ...string) *Defaults {
+	for _, column := range columns {
+		d.allowGroupBy[column] = true
+	}
+
+	return d
+}
+
 func (d *Defaults) aggregate(model string, fields ...aggregateField) *Defaults {
 	if d.aggregation[model] == nil {
 		d.aggregation[model] = make([]aggregateField, 0)
@@ -101,13 +232,28 @@ func (d *Defaults) Count(model, field string, alias string) *Defaults {
 }
 
 func (l *Liqu) processDefaults() error {
-	for k, v := range l.defaults.orderBy {
-		err := l.processOrderBy(k, v.String())
+	for k, v := range l.defaults.normalize {
+		err := l.applyNormalize(k, v)
 		if err != nil {
 			return err
 		}
 	}
 
+	for k, v := range l.defaults.window {
+		err := l.processWindow(k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	// aggregates go first, so their aliases can be used to filter and order on
+	for model, fields := range l.defaults.aggregation {
+		for _, field := range fields {
+			l.processSelect(model, field.Field)
+			l.processSelectAggregate(model, field.Field, field.Alias, field.Func)
+		}
+	}
+
 	for _, v := range l.defaults.where {
 		err := l.processWhere(And, v.column, v.op.String(), v.val, true)
 		if err != nil {
@@ -121,12 +267,97 @@ func (l *Liqu) processDefaults() error {
 		}
 	}
 
-	for model, fields := range l.defaults.aggregation {
-		for _, field := range fields {
-			l.processSelect(model, field.Field)
-			l.processSelectAggregate(model, field.Field, field.Alias, field.Func)
+	for model, fields := range l.defaults.distinctOn {
+		reg, ok := l.registry[model]
+		if !ok || reg.branch == nil {
+			return fmt.Errorf("invalid distinct on model %s", model)
 		}
+
+		reg.branch.distinctOn = append(reg.branch.distinctOn, fields...)
 	}
 
 	return nil
 }
+
+// processDefaultOrders adds the default orders around the order of the user, according to the merge mode.
+// before reports whether the order of the user has yet to be parsed.
+func (l *Liqu) processDefaultOrders(userOrder string, before bool) error {
+	if l.defaults == nil {
+		return nil
+	}
+
+	mode := l.defaults.orderMerge
+	if mode == "" {
+		mode = OrderAppend
+	}
+
+	// the defaults go in front of the order of the user for append, after it otherwise
+	if before != (mode == OrderAppend) {
+		return nil
+	}
+
+	ordered := make(map[string]bool)
+	for _, order := range strings.Split(userOrder, ",") {
+		if strings.TrimSpace(order) == "" {
+			continue
+		}
+
+		model, field := l.splitColumn(strings.Split(order, "|")[0])
+		ordered[fmt.Sprintf("%s.%s", model, field)] = true
+	}
+
+	if mode == OrderReplace && len(ordered) > 0 {
+		return nil
+	}
+
+	for _, v := range l.defaults.orderBy {
+		model, field := l.splitColumn(v.column)
+		if mode == OrderPrepend && ordered[fmt.Sprintf("%s.%s", model, field)] {
+			continue
+		}
+
+		err := l.processOrderBy(v.column, v.direction.String(), v.modifiers()...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// aggregateAllowed reports whether the function can be requested on the field, the allowlist
+// can name the column with or without the model of the root.
+func (l *Liqu) aggregateAllowed(model, field string, fn Aggregator) bool {
+	if l.defaults == nil {
+		return false
+	}
+
+	for column, funcs := range l.defaults.allowAggregate {
+		if m, f := l.splitColumn(column); m != model || f != field {
+			continue
+		}
+
+		for _, v := range funcs {
+			if v == fn {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// groupByAllowed reports whether the field can be grouped on by request
+func (l *Liqu) groupByAllowed(model, field string) bool {
+	if l.defaults == nil {
+		return false
+	}
+
+	for column := range l.defaults.allowGroupBy {
+		if m, f := l.splitColumn(column); m == model && f == field {
+			return true
+		}
+	}
+
+	return false
+}
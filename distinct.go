@@ -0,0 +1,66 @@
+package liqu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// distinctOn returns the DISTINCT ON clause of the branch together with the order of the rows it picks from.
+// postgres requires the DISTINCT ON columns to lead the ORDER BY, so they are moved in front of the other orders,
+// keeping their direction when they were ordered on. the returned rows are still ordered on the order of the user,
+// by the wrapping query for the root and by the aggregate for a relation.
+func (l *Liqu) distinctOn(branch *branch) (string, *OrderBuilder, error) {
+	if len(branch.distinctOn) == 0 {
+		return "", branch.order, nil
+	}
+
+	switch {
+	case branch == l.tree && branch.anonymous:
+		return "", nil, fmt.Errorf("invalid distinct on %s, an anonymous root is not supported", branch.as)
+	case branch != l.tree && (!branch.slice || branch.isCTE):
+		return "", nil, fmt.Errorf("invalid distinct on %s, only slice relations are supported", branch.as)
+	case len(branch.aggregateFields) > 0:
+		return "", nil, fmt.Errorf("invalid distinct on %s, it can not be combined with aggregates", branch.as)
+	}
+
+	var (
+		columns = make([]string, 0)
+		order   = NewOrderBuilder()
+	)
+
+	for _, field := range branch.distinctOn {
+		field = l.resolveField(branch.as, field)
+		if _, ok := branch.registry.fieldDatabase[field]; !ok {
+			return "", nil, fmt.Errorf("invalid distinct on field %s.%s", branch.as, field)
+		}
+
+		column := l.fieldColumn(branch.as, field)
+		if order.HasOrderBy(column) {
+			continue
+		}
+
+		lead := Order{Column: column, Direction: Asc}
+		for _, v := range branch.order.orders {
+			if v.matches(column) {
+				lead = v
+			}
+		}
+
+		// the expression has to be the same as the one of the DISTINCT ON
+		lead.normalize = normalize{}
+
+		columns = append(columns, column)
+		order.order(lead)
+		branch.groupBy.GroupBy(column)
+	}
+
+	for _, v := range branch.order.orders {
+		if v.Column == "" || order.HasOrderBy(v.Column) {
+			continue
+		}
+
+		order.order(v)
+	}
+
+	return fmt.Sprintf("DISTINCT ON (%s)", strings.Join(columns, ", ")), order, nil
+}
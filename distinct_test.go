@@ -0,0 +1,97 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+)
+
+type (
+	Price struct {
+		ID        int     `db:"id"`
+		ProductID int     `db:"product_id"`
+		Amount    float64 `db:"amount"`
+		Date      string  `db:"date"`
+	}
+
+	Product struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+
+	LatestPriceList struct {
+		Price Price `distinct_on:"ProductID"`
+	}
+
+	ProductPriceList struct {
+		Product Product
+
+		Prices []Price `related:"Prices.ProductID=Product.ID" join:"left" distinct_on:"Amount"`
+	}
+)
+
+func (m *Price) Table() string {
+	return "price"
+}
+
+func (m *Price) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
+func (m *Product) Table() string {
+	return "product"
+}
+
+func (m *Product) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
+func TestWithDistinctOn(t *testing.T) {
+	test := []struct {
+		Name     string
+		Source   interface{}
+		OrderBy  string
+		Expected string
+	}{
+		{
+			Name:     "root",
+			Source:   make([]LatestPriceList, 0),
+			OrderBy:  "Price.Date|DESC",
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Price" ) AS "Price" FROM ( SELECT DISTINCT ON ("price"."product_id") "price"."date" AS "Date", "price"."id" AS "ID" FROM "price" GROUP BY "price"."date", "price"."id", "price"."product_id" ORDER BY "price"."product_id" ASC, "price"."date" DESC, "price"."id" ASC) AS "Price" ORDER BY "Date" DESC, "ID" ASC LIMIT 25 OFFSET 0 ) q`,
+		},
+		{
+			Name:     "relation",
+			Source:   make([]ProductPriceList, 0),
+			OrderBy:  "Prices.Date|DESC",
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Product" ) AS "Product", "Prices"."Prices" AS "Prices" FROM ( SELECT "product"."id" AS "ID" FROM "product" GROUP BY "product"."id" ORDER BY "product"."id" ASC) AS "Product" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "price"."id", 'Date', "price"."date" ) ORDER BY "price"."date" DESC ) FILTER ( WHERE jsonb_build_object( 'ID', "price"."id", 'Date', "price"."date" ) IS NOT NULL ),'[]' ) AS "Prices" FROM ( SELECT DISTINCT ON ("price"."amount") * FROM "price" WHERE product_id = "Product"."ID" ORDER BY "price"."amount" ASC, "price"."date" DESC ) AS "price" ) AS "Prices" ON true ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`,
+		},
+	}
+
+	for _, te := range test {
+		li := New(context.TODO(), &Filters{OrderBy: te.OrderBy})
+
+		err := li.FromSource(te.Source)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		sqlQuery, _ := li.SQL()
+		if sqlQuery != te.Expected {
+			t.Errorf("%s expected:\n%s\ngot:\n%s", te.Name, te.Expected, sqlQuery)
+		}
+	}
+
+	li := New(context.TODO(), nil).
+		WithDefaults(NewDefaults().DistinctOn("Product", "Name"))
+
+	if err := li.FromSource(make([]ProductPriceList, 0)); err != nil {
+		t.Error(err)
+	}
+
+	li = New(context.TODO(), nil).
+		WithDefaults(NewDefaults().DistinctOn("Product", "Unknown"))
+
+	if err := li.FromSource(make([]ProductPriceList, 0)); err == nil {
+		t.Error("expected an error on an unknown distinct on field")
+	}
+}
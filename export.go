@@ -0,0 +1,481 @@
+package liqu
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Executor runs a query, it is implemented by *sql.DB, *sql.Tx and *sql.Conn
+	Executor interface {
+		QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	}
+
+	// ExportFormat is the format rows are written in by Export
+	ExportFormat string
+
+	// SliceMode decides how the slice relations of a row end up in the columns of an export
+	SliceMode string
+
+	ExportOptions struct {
+		Format ExportFormat
+		Slices SliceMode
+		// Separator joins the values of slice relations, it defaults to ", "
+		Separator string
+	}
+
+	// exporter flattens the json rows of the root into the columns of the export and writes them
+	exporter struct {
+		columns []string
+		// position is the index of the first column of every path, the fields are flattened in the order of the columns
+		position map[string]int
+		root     string
+		options  ExportOptions
+		buf      *bufio.Writer
+		csv      *csv.Writer
+	}
+
+	// record is a flattened row, keyed by the path of the column like `Author.Name`
+	record map[string]interface{}
+)
+
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportNDJSON ExportFormat = "ndjson"
+
+	// SlicesJoined joins the values of a slice relation into a single column, this is the default.
+	SlicesJoined SliceMode = "joined"
+	// SlicesExploded writes a row per element of a slice relation, repeating the columns of the root. only one slice
+	// relation is exploded per row, the first in the order of the columns, and the slices within its elements in
+	// turn. its siblings are joined, as exploding them as well would return every combination of their elements.
+	SlicesExploded SliceMode = "exploded"
+)
+
+// ForExport builds the query for Export, it has to be called before FromSource. every row of the root matching the
+// filters is returned as a row of its own, paging is ignored.
+func (l *Liqu) ForExport() *Liqu {
+	l.rows = true
+	l.unpaged = true
+
+	return l
+}
+
+// paging returns the filters the root is paginated with, an export returns all rows
+func (l *Liqu) paging() *Filters {
+	if !l.unpaged || l.filters == nil {
+		return l.filters
+	}
+
+	filters := *l.filters
+	filters.DisablePaging = true
+
+	return &filters
+}
+
+// Export runs the query and streams the rows to w as CSV or NDJSON, the fields of relations are flattened into
+// columns like `Author.Name`. the rows are written as they are read, so the result is never held as a whole.
+func (l *Liqu) Export(ctx context.Context, exec Executor, w io.Writer, options ExportOptions) error {
+	if !l.rows || !l.unpaged || l.tree == nil {
+		return errors.New("[liqu] export requires ForExport to be called before FromSource")
+	}
+
+	e, err := l.newExporter(w, options)
+	if err != nil {
+		return err
+	}
+
+	rows, err := exec.QueryContext(ctx, l.sqlQuery, l.sqlParams...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row []byte
+		if err = rows.Scan(&row); err != nil {
+			return err
+		}
+
+		if err = e.write(row); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	return e.flush()
+}
+
+func (l *Liqu) newExporter(w io.Writer, options ExportOptions) (*exporter, error) {
+	if options.Format == "" {
+		options.Format = ExportCSV
+	}
+
+	if options.Slices == "" {
+		options.Slices = SlicesJoined
+	}
+
+	if options.Separator == "" {
+		options.Separator = ", "
+	}
+
+	e := &exporter{
+		columns:  l.exportColumns(),
+		position: make(map[string]int),
+		options:  options,
+		buf:      bufio.NewWriter(w),
+	}
+
+	for k, v := range e.columns {
+		parts := strings.Split(v, ".")
+		for i := range parts {
+			path := strings.Join(parts[:i+1], ".")
+			if _, ok := e.position[path]; !ok {
+				e.position[path] = k
+			}
+		}
+	}
+
+	if !l.tree.anonymous {
+		e.root = l.tree.jsonKey()
+	}
+
+	switch options.Format {
+	case ExportCSV:
+		e.csv = csv.NewWriter(e.buf)
+
+		if err := e.csv.Write(e.columns); err != nil {
+			return nil, err
+		}
+	case ExportNDJSON:
+	default:
+		return nil, fmt.Errorf("invalid export format %s", options.Format)
+	}
+
+	switch options.Slices {
+	case SlicesJoined, SlicesExploded:
+	default:
+		return nil, fmt.Errorf("invalid export slice mode %s", options.Slices)
+	}
+
+	return e, nil
+}
+
+// exportColumns returns the columns of the export, the fields returned for the root followed by the ones of its relations
+func (l *Liqu) exportColumns() []string {
+	if len(l.tree.aggregateFields) > 0 {
+		var columns []string
+		for _, v := range l.tree.aggregateGroups {
+			columns = append(columns, l.aggregateGroupKey(l.tree, v))
+		}
+
+		for _, v := range l.tree.aggregateFields {
+			columns = append(columns, v.Alias)
+		}
+
+		return columns
+	}
+
+	fields := make([]string, 0)
+	for _, v := range l.tree.selectedFields {
+		fields = appendUnique(fields, v)
+	}
+
+	for _, v := range l.registry[l.tree.as].fieldOrder {
+		if l.tree.referencedFields[v] {
+			fields = appendUnique(fields, v)
+		}
+	}
+
+	var columns []string
+	for _, v := range fields {
+		if !l.fieldHidden(l.tree.as, v) {
+			columns = append(columns, l.fieldKey(l.tree.as, v))
+		}
+	}
+
+	for _, v := range l.tree.relationAggregates {
+		columns = append(columns, v.key)
+	}
+
+	for _, v := range l.tree.branches {
+		columns = append(columns, l.exportBranchColumns(v, "")...)
+	}
+
+	return columns
+}
+
+func (l *Liqu) exportBranchColumns(branch *branch, prefix string) []string {
+	if branch.excluded || len(branch.relations) == 0 {
+		return nil
+	}
+
+	key := branch.jsonKey()
+	if branch.isCTE {
+		key = branch.as
+	}
+	prefix = exportPath(prefix, key)
+
+	var columns []string
+	for _, v := range branch.selectedFields {
+		if !l.fieldHidden(branch.as, v) {
+			columns = appendUnique(columns, exportPath(prefix, l.fieldKey(branch.as, v)))
+		}
+	}
+
+	for _, v := range branch.branches {
+		columns = append(columns, l.exportBranchColumns(v, prefix)...)
+	}
+
+	return columns
+}
+
+// write flattens the json row and writes the records it results in
+func (e *exporter) write(row []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(row))
+	decoder.UseNumber()
+
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return err
+	}
+
+	// the fields of the root are returned under its key, in the export these are the columns without a prefix
+	if root, ok := values[e.root].(map[string]interface{}); ok && e.root != "" {
+		delete(values, e.root)
+
+		for k, v := range root {
+			values[k] = v
+		}
+	}
+
+	explode := true
+	for _, r := range e.flatten("", values, &explode) {
+		if err := e.writeRecord(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *exporter) writeRecord(r record) error {
+	if e.csv != nil {
+		cells := make([]string, len(e.columns))
+		for k, v := range e.columns {
+			cells[k] = exportValue(r[v])
+		}
+
+		return e.csv.Write(cells)
+	}
+
+	e.buf.WriteString("{")
+	for k, v := range e.columns {
+		if k > 0 {
+			e.buf.WriteString(",")
+		}
+
+		key, _ := json.Marshal(v)
+		value, err := json.Marshal(r[v])
+		if err != nil {
+			return err
+		}
+
+		e.buf.Write(key)
+		e.buf.WriteString(":")
+		e.buf.Write(value)
+	}
+	_, err := e.buf.WriteString("}\n")
+
+	return err
+}
+
+func (e *exporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+
+	return e.buf.Flush()
+}
+
+// flatten returns the records of the value under the path, a single one unless a slice is exploded. explode reports
+// whether a slice can still be exploded, which is the case for the first one of a row and of an exploded element.
+func (e *exporter) flatten(path string, value interface{}, explode *bool) []record {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		records := []record{{}}
+		for _, key := range e.keys(path, v) {
+			records = crossRecords(records, e.flatten(exportPath(path, key), v[key], explode))
+		}
+
+		return records
+	case []interface{}:
+		if e.options.Slices == SlicesExploded && *explode {
+			*explode = false
+
+			var records []record
+			for _, child := range v {
+				nested := true
+				records = append(records, e.flatten(path, child, &nested)...)
+			}
+
+			if len(records) == 0 {
+				return []record{{}}
+			}
+
+			return records
+		}
+
+		joined := record{}
+		for column, values := range e.collect(path, v) {
+			cells := make([]string, len(values))
+			for k, cell := range values {
+				cells[k] = exportValue(cell)
+			}
+
+			joined[column] = strings.Join(cells, e.options.Separator)
+		}
+
+		return []record{joined}
+	default:
+		return []record{{path: v}}
+	}
+}
+
+// collect returns the values of every element of the slice per column. a slice within an element ends up as an
+// array per element, joining it as well would leave no way to tell which element its values belong to.
+func (e *exporter) collect(path string, v []interface{}) map[string][]interface{} {
+	var (
+		elements = make([]record, len(v))
+		values   = make(map[string][]interface{})
+	)
+
+	for k, child := range v {
+		elements[k] = e.element(path, child)
+
+		for column := range elements[k] {
+			values[column] = nil
+		}
+	}
+
+	// an element missing a column still takes its place, so the values of the columns line up
+	for column := range values {
+		for _, r := range elements {
+			values[column] = append(values[column], r[column])
+		}
+	}
+
+	return values
+}
+
+// element flattens an element of a joined slice into a single record
+func (e *exporter) element(path string, value interface{}) record {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		r := record{}
+		for key, child := range v {
+			for column, cell := range e.element(exportPath(path, key), child) {
+				r[column] = cell
+			}
+		}
+
+		return r
+	case []interface{}:
+		r := record{}
+		for column, values := range e.collect(path, v) {
+			r[column] = values
+		}
+
+		return r
+	default:
+		return record{path: v}
+	}
+}
+
+// keys returns the keys of the object in the order of the columns, the ones without a column go last
+func (e *exporter) keys(path string, v map[string]interface{}) []string {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+
+	position := func(key string) int {
+		if k, ok := e.position[exportPath(path, key)]; ok {
+			return k
+		}
+
+		return len(e.columns)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		if position(keys[i]) != position(keys[j]) {
+			return position(keys[i]) < position(keys[j])
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+// crossRecords combines every record of a with every record of b
+func crossRecords(a, b []record) []record {
+	out := make([]record, 0, len(a)*len(b))
+
+	for _, left := range a {
+		for _, right := range b {
+			r := make(record, len(left)+len(right))
+			for k, v := range left {
+				r[k] = v
+			}
+
+			for k, v := range right {
+				r[k] = v
+			}
+
+			out = append(out, r)
+		}
+	}
+
+	return out
+}
+
+func exportPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "." + key
+}
+
+func exportValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -0,0 +1,164 @@
+package liqu
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+type (
+	AuthorArticleList struct {
+		Author Author
+
+		Articles []Article `related:"Articles.AuthorID=Author.ID" join:"left" json:"articles"`
+	}
+
+	AuthorArticleTagList struct {
+		Author Author
+
+		Articles []Article `related:"Articles.AuthorID=Author.ID" join:"left" json:"articles"`
+		Tags     []Tag     `related:"Tags.ID=Author.ID" join:"left" json:"tags"`
+	}
+)
+
+func TestForExport(t *testing.T) {
+	filters := &Filters{
+		Select:  "Author.name,Articles.title",
+		Where:   "Author.name|ILIKE|jo",
+		OrderBy: "Author.name|ASC",
+		Page:    3,
+	}
+
+	li := New(context.TODO(), filters).ForExport()
+
+	err := li.FromSource(make([]AuthorArticleList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	expected := `SELECT to_jsonb(q) FROM ( SELECT to_jsonb( jsonb_build_object( 'id', "Author"."ID", 'name', "Author"."Name" ) ) AS "Author", "Articles"."Articles" AS "articles" FROM ( SELECT "author"."name" AS "Name", "author"."id" AS "ID" FROM "author" WHERE "author"."name" ILIKE $1 GROUP BY "author"."name", "author"."id" ORDER BY "author"."name" ASC, "author"."id" ASC) AS "Author" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'id', "article"."id", 'title', "article"."title" ) ) FILTER ( WHERE jsonb_build_object( 'id', "article"."id", 'title', "article"."title" ) IS NOT NULL ),'[]' ) AS "Articles" FROM "article" WHERE author_id = "Author"."ID" ) AS "Articles" ON true ORDER BY "Name" ASC, "ID" ASC ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	row := []byte(`{"Author": {"id": 1, "name": "Jo, Jr."}, "articles": [{"id": 3, "title": "Go"}, {"id": 4, "title": "SQL"}]}`)
+
+	test := []struct {
+		Options  ExportOptions
+		Expected string
+	}{
+		{
+			Options:  ExportOptions{},
+			Expected: "id,name,articles.id,articles.title\n1,\"Jo, Jr.\",\"3, 4\",\"Go, SQL\"\n",
+		},
+		{
+			Options:  ExportOptions{Slices: SlicesExploded},
+			Expected: "id,name,articles.id,articles.title\n1,\"Jo, Jr.\",3,Go\n1,\"Jo, Jr.\",4,SQL\n",
+		},
+		{
+			Options:  ExportOptions{Format: ExportNDJSON, Separator: "|"},
+			Expected: "{\"id\":1,\"name\":\"Jo, Jr.\",\"articles.id\":\"3|4\",\"articles.title\":\"Go|SQL\"}\n",
+		},
+	}
+
+	for _, te := range test {
+		var buf bytes.Buffer
+
+		e, err := li.newExporter(&buf, te.Options)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if err = e.write(row); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if err = e.flush(); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if buf.String() != te.Expected {
+			t.Errorf("%+v expected:\n%q\ngot:\n%q", te.Options, te.Expected, buf.String())
+		}
+	}
+
+	li = New(context.TODO(), filters)
+	if err = li.FromSource(make([]AuthorArticleList, 0)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err = li.Export(context.TODO(), nil, &bytes.Buffer{}, ExportOptions{}); err == nil {
+		t.Error("expected an error on an export without ForExport")
+	}
+}
+
+func TestExportSlices(t *testing.T) {
+	test := []struct {
+		Select   string
+		Source   interface{}
+		Row      string
+		Options  ExportOptions
+		Expected string
+	}{
+		{
+			Select:   "Author.name,Articles.title,Tags.Name",
+			Source:   make([]AuthorArticleTagList, 0),
+			Row:      `{"Author": {"id": 1, "name": "Jo"}, "articles": [{"id": 3, "title": "Go"}, {"id": 4, "title": "SQL"}], "tags": [{"ID": 5, "Name": "db"}, {"ID": 6, "Name": "web"}]}`,
+			Options:  ExportOptions{Slices: SlicesExploded},
+			Expected: "id,name,articles.id,articles.title,tags.ID,tags.Name\n1,Jo,3,Go,\"5, 6\",\"db, web\"\n1,Jo,4,SQL,\"5, 6\",\"db, web\"\n",
+		},
+		{
+			Select:   "Project.Name,Tags.Name",
+			Source:   make([]Tree, 0),
+			Row:      `{"Project": {"ID": 1, "Name": "Liqu"}, "ProjectTags": [{"Tags": [{"ID": 1, "Name": "go"}, {"ID": 2, "Name": "sql"}]}, {"Tags": [{"ID": 3, "Name": "db"}]}]}`,
+			Options:  ExportOptions{},
+			Expected: "ID,Name,ProjectTags.Tags.ID,ProjectTags.Tags.Name\n1,Liqu,\"[1,2], [3]\",\"[\"\"go\"\",\"\"sql\"\"], [\"\"db\"\"]\"\n",
+		},
+		{
+			Select:   "Project.Name,Tags.Name",
+			Source:   make([]Tree, 0),
+			Row:      `{"Project": {"ID": 1, "Name": "Liqu"}, "ProjectTags": [{"Tags": [{"ID": 1, "Name": "go"}, {"ID": 2, "Name": "sql"}]}, {"Tags": [{"ID": 3, "Name": "db"}]}]}`,
+			Options:  ExportOptions{Slices: SlicesExploded},
+			Expected: "ID,Name,ProjectTags.Tags.ID,ProjectTags.Tags.Name\n1,Liqu,1,go\n1,Liqu,2,sql\n1,Liqu,3,db\n",
+		},
+	}
+
+	for _, te := range test {
+		li := New(context.TODO(), &Filters{Select: te.Select}).ForExport()
+
+		err := li.FromSource(te.Source)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		var buf bytes.Buffer
+
+		e, err := li.newExporter(&buf, te.Options)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if err = e.write([]byte(te.Row)); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if err = e.flush(); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if buf.String() != te.Expected {
+			t.Errorf("%s %+v expected:\n%q\ngot:\n%q", te.Select, te.Options, te.Expected, buf.String())
+		}
+	}
+}
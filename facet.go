@@ -0,0 +1,255 @@
+package liqu
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type (
+	// Facet is a value of a faceted field with the number of rows of the root having it
+	Facet struct {
+		Value interface{}
+		Count int
+	}
+
+	// facet is the field the rows of the root are counted on, when a query is built for a facet
+	facet struct {
+		name   string
+		model  string
+		field  string
+		column string
+		// histogram counts the rows per bucket of the value instead of per value
+		histogram *Histogram
+		// totals aggregates all rows instead of counting them per value
+		totals []Total
+	}
+)
+
+// WithFacets counts the rows of the root per value of the fields, like `Category.Name` or `Article.Status`, next to
+// the page. each facet is counted under all conditions of the request except its own, the fields have to be on the
+// root or on a single valued relation of the root. the counts are read with Filters.Facets after PostProcess.
+func (l *Liqu) WithFacets(columns ...string) *Liqu {
+	l.facets = append(l.facets, columns...)
+
+	return l
+}
+
+// resolveFacet validates the field of the facet the query is built for and returns the column it is counted on
+func (l *Liqu) resolveFacet() error {
+	if l.facet == nil {
+		return nil
+	}
+
+	if len(l.cte) > 0 {
+		return fmt.Errorf("invalid facet %s, facets can not be combined with CTEs", l.facet.name)
+	}
+
+	if l.facet.totals != nil {
+		return l.resolveTotals()
+	}
+
+	var err error
+
+	l.facet.model, l.facet.field, l.facet.column, err = l.facetColumn(l.facet.name)
+	if err != nil {
+		return err
+	}
+
+	if l.facet.histogram != nil {
+		return l.resolveHistogram()
+	}
+
+	return nil
+}
+
+// facetColumn returns the column of the field as it is available on the rows of the root,
+// which are the fields of the root and the fields of its single valued relations.
+func (l *Liqu) facetColumn(name string) (string, string, string, error) {
+	model, field := l.splitColumn(name)
+
+	reg, ok := l.registry[model]
+	if !ok || reg.branch == nil {
+		return "", "", "", fmt.Errorf("invalid facet %s", name)
+	}
+
+	if _, ok = reg.fieldDatabase[field]; !ok || reg.fieldWindow[field] {
+		return "", "", "", fmt.Errorf("invalid facet %s", name)
+	}
+
+	if _, ok = reg.branch.subQuery[field]; ok {
+		return "", "", "", fmt.Errorf("invalid facet %s, sub queries can not be faceted", name)
+	}
+
+	switch {
+	case reg.branch == l.tree:
+		l.tree.referencedFields[field] = true
+
+		if l.tree.anonymous {
+			return model, field, l.fieldColumn(model, field), nil
+		}
+
+		return model, field, fmt.Sprintf(`"%s"."%s"`, l.tree.as, field), nil
+	case l.isSingleRelation(reg.branch):
+		if _, ok = reg.fieldExpression[field]; ok {
+			return "", "", "", fmt.Errorf("invalid facet %s, computed fields of relations can not be faceted", name)
+		}
+
+		// the column is exposed by the lateral join of the relation
+		reg.branch.referencedFields[field] = true
+
+		return model, field, fmt.Sprintf(`"%s"."%s"`, reg.branch.as, reg.fieldDatabase[field]), nil
+	}
+
+	return "", "", "", fmt.Errorf("invalid facet %s, only fields of the root and its single valued relations can be faceted", name)
+}
+
+// facetExcluded reports whether the condition on the field is left out, as a facet is counted without its own conditions
+func (l *Liqu) facetExcluded(model, field string) bool {
+	return l.facet != nil && l.facet.model == model && l.facet.field == field
+}
+
+// pruneFacet leaves out the relations that do not affect which rows of the root are counted
+func (l *Liqu) pruneFacet() error {
+	if len(l.tree.aggregateFields) > 0 {
+		return fmt.Errorf("invalid facet %s, facets can not be combined with aggregates", l.facet.name)
+	}
+
+	for _, v := range l.tree.branches {
+		if v.isCTE || v.searched() || v.joinDirection == InnerJoin || v.as == l.facet.model || l.facet.totalOn(v.as) {
+			continue
+		}
+
+		v.excluded = true
+	}
+
+	return nil
+}
+
+// facetSelects returns the columns of the rows of a facet, the columns they are grouped on and the order they are returned in
+func (l *Liqu) facetSelects() ([]string, []string, string) {
+	if l.facet.histogram != nil {
+		return l.histogramSelects()
+	}
+
+	if l.facet.totals != nil {
+		return l.totalSelects(), nil, ""
+	}
+
+	return []string{
+		fmt.Sprintf(`%s AS "Value"`, l.facet.column),
+		`count(*) AS "Count"`,
+	}, []string{l.facet.column}, `"Count" DESC, "Value" ASC`
+}
+
+// traverseFacets builds the query of every facet, histogram and the totals and returns them next to the rows in one
+// json object, the rows under "Data", the facets under "Facets", the histograms under "Histograms" and the totals
+// under "Totals". the parameters of the facets follow the ones of the rows.
+func (l *Liqu) traverseFacets() error {
+	if !l.enveloped() {
+		return nil
+	}
+
+	var facets, histograms []string
+	for _, name := range l.facets {
+		query, err := l.facetQuery(&facet{name: strings.TrimSpace(name)})
+		if err != nil {
+			return err
+		}
+
+		facets = append(facets, query)
+	}
+
+	for _, v := range l.histograms {
+		query, err := l.facetQuery(v)
+		if err != nil {
+			return err
+		}
+
+		histograms = append(histograms, query)
+	}
+
+	pairs := []string{fmt.Sprintf(`'Data', ( %s )`, l.sqlQuery)}
+	if len(facets) > 0 {
+		pairs = append(pairs, fmt.Sprintf(`'Facets', jsonb_build_object( %s )`, strings.Join(facets, ", ")))
+	}
+
+	if len(histograms) > 0 {
+		pairs = append(pairs, fmt.Sprintf(`'Histograms', jsonb_build_object( %s )`, strings.Join(histograms, ", ")))
+	}
+
+	if len(l.totals) > 0 {
+		totals, err := l.facetQuery(&facet{name: "Totals", totals: append([]Total{}, l.totals...)})
+		if err != nil {
+			return err
+		}
+
+		pairs = append(pairs, totals)
+	}
+
+	l.sqlQuery = fmt.Sprintf(`SELECT jsonb_build_object( %s )`, strings.Join(pairs, ", "))
+
+	return nil
+}
+
+// facetQuery builds the query of the facet on the same source and conditions and returns it as a key value pair
+func (l *Liqu) facetQuery(f *facet) (string, error) {
+	fl := New(l.ctx, &Filters{Where: l.filters.Where, DisablePaging: true}).WithDefaults(l.defaults)
+	fl.children = l.children
+	fl.geo = l.geo
+	fl.subQueries = l.subQueries
+	fl.noTieBreaker = true
+	fl.facet = f
+	fl.sqlParams = l.sqlParams
+
+	err := fl.FromSource(l.source)
+	if err != nil {
+		return "", err
+	}
+
+	l.sqlParams = fl.sqlParams
+
+	return fmt.Sprintf(`'%s', ( %s )`, strings.ReplaceAll(f.name, "'", "''"), fl.sqlQuery), nil
+}
+
+// enveloped reports whether the rows are returned in a json object next to the facets, histograms or totals
+func (l *Liqu) enveloped() bool {
+	if l.rows {
+		return false
+	}
+
+	return len(l.facets) > 0 || len(l.histograms) > 0 || len(l.totals) > 0
+}
+
+// sliceResult reports whether the query returns an array, the totals are a single object whatever the source is
+func (l *Liqu) sliceResult() bool {
+	if l.facet != nil {
+		return l.facet.totals == nil
+	}
+
+	return l.sourceSlice
+}
+
+// postProcessFacets reads the facets, histograms and totals from the json object of the result and returns the rows
+func (l *Liqu) postProcessFacets(pp string) string {
+	if !l.enveloped() {
+		return pp
+	}
+
+	var envelope struct {
+		Data       json.RawMessage
+		Facets     map[string][]Facet
+		Histograms map[string]json.RawMessage
+		Totals     map[string]interface{}
+	}
+
+	if err := json.Unmarshal([]byte(pp), &envelope); err != nil {
+		return pp
+	}
+
+	l.filters.facets = envelope.Facets
+	l.filters.histograms = envelope.Histograms
+	l.filters.totals = envelope.Totals
+
+	return string(envelope.Data)
+}
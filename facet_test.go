@@ -0,0 +1,97 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+)
+
+type (
+	ArticleFacetList struct {
+		Article Article
+
+		Author   Author   `related:"Author.ID=Article.AuthorID" join:"left"`
+		Category Category `related:"Category.ID=Article.CategoryID" join:"left"`
+	}
+)
+
+func TestWithFacets(t *testing.T) {
+	filters := &Filters{
+		Where: "Article.Title|ILIKE|go,Author.Name|=|jane,Article.CategoryID|=|3",
+	}
+
+	li := New(context.TODO(), filters).
+		WithFacets("Category.Name", "Article.category_id")
+
+	err := li.FromSource(make([]ArticleFacetList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT jsonb_build_object( 'Data', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( jsonb_build_object( 'id', "Article"."ID", 'title', "Article"."Title", 'category_id', "Article"."CategoryID" ) ) AS "Article", "Author"."Author" AS "Author", "Category"."Category" AS "Category" FROM ( SELECT "article"."id" AS "ID", "article"."title" AS "Title", "article"."category_id" AS "CategoryID", "article"."author_id" AS "AuthorID" FROM "article" WHERE "article"."title" ILIKE $1 AND "article"."category_id" = $3 GROUP BY "article"."id", "article"."title", "article"."category_id", "article"."author_id" ORDER BY "article"."id" ASC) AS "Article" INNER JOIN LATERAL ( SELECT to_jsonb( jsonb_build_object( 'id', "author"."id", 'name', "author"."name" ) ) AS "Author" FROM "author" WHERE "author"."name" = $2 AND id = "Article"."AuthorID" ) AS "Author" ON true LEFT JOIN LATERAL ( SELECT to_jsonb( jsonb_build_object( 'ID', "category"."id" ) ) AS "Category" FROM "category" WHERE id = "Article"."CategoryID" ) AS "Category" ON true WHERE "Author" IS NOT NULL ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q ), 'Facets', jsonb_build_object( 'Category.Name', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT "Category"."name" AS "Value", count(*) AS "Count" FROM ( SELECT "article"."id" AS "ID", "article"."title" AS "Title", "article"."category_id" AS "CategoryID", "article"."author_id" AS "AuthorID" FROM "article" WHERE "article"."title" ILIKE $4 AND "article"."category_id" = $6 GROUP BY "article"."id", "article"."title", "article"."category_id", "article"."author_id" ) AS "Article" INNER JOIN LATERAL ( SELECT to_jsonb( jsonb_build_object( 'id', "author"."id", 'name', "author"."name" ) ) AS "Author" FROM "author" WHERE "author"."name" = $5 AND id = "Article"."AuthorID" ) AS "Author" ON true LEFT JOIN LATERAL ( SELECT name, to_jsonb( jsonb_build_object( 'ID', "category"."id" ) ) AS "Category" FROM "category" WHERE id = "Article"."CategoryID" ) AS "Category" ON true WHERE "Author" IS NOT NULL GROUP BY "Category"."name" ORDER BY "Count" DESC, "Value" ASC ) q ), 'Article.category_id', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT "Article"."CategoryID" AS "Value", count(*) AS "Count" FROM ( SELECT "article"."id" AS "ID", "article"."title" AS "Title", "article"."author_id" AS "AuthorID", "article"."category_id" AS "CategoryID" FROM "article" WHERE "article"."title" ILIKE $7 GROUP BY "article"."id", "article"."title", "article"."author_id", "article"."category_id" ) AS "Article" INNER JOIN LATERAL ( SELECT to_jsonb( jsonb_build_object( 'id', "author"."id", 'name', "author"."name" ) ) AS "Author" FROM "author" WHERE "author"."name" = $8 AND id = "Article"."AuthorID" ) AS "Author" ON true WHERE "Author" IS NOT NULL GROUP BY "Article"."CategoryID" ORDER BY "Count" DESC, "Value" ASC ) q ) ) )`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 8 {
+		t.Errorf("expected 8 params, got %d", len(sqlParams))
+	}
+
+	result := li.PostProcess(`{"Data": [{"totalrows": 1, "Article": {"id": 1}}], "Facets": {"Article.category_id": [{"Count": 2, "Value": 3}], "Category.Name": [{"Count": 1, "Value": "Go"}]}}`)
+	if result != `[{ "Article": {"id": 1}}]` {
+		t.Errorf("expected the rows of the result, got %s", result)
+	}
+
+	facets := li.Filters().Facets()
+	if len(facets["Category.Name"]) != 1 || facets["Category.Name"][0].Value != "Go" || facets["Category.Name"][0].Count != 1 {
+		t.Errorf("expected the facet of Category.Name, got %+v", facets["Category.Name"])
+	}
+
+	if li.Filters().TotalResults() != 1 {
+		t.Errorf("expected 1 result, got %d", li.Filters().TotalResults())
+	}
+
+	li = New(context.TODO(), nil).WithFacets("Article.Body|x")
+	if err = li.FromSource(make([]ArticleFacetList, 0)); err == nil {
+		t.Error("expected an error for an unknown facet")
+	}
+}
+
+func TestWithFacetsInGroups(t *testing.T) {
+	test := []struct {
+		Where    string
+		Expected string
+	}{
+		{
+			Where:    "Price.Amount|>=|10,(OR,Price.ProductID|=|4)",
+			Expected: `SELECT jsonb_build_object( 'Data', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Price" ) AS "Price" FROM ( SELECT "price"."id" AS "ID", "price"."amount" AS "Amount", "price"."product_id" AS "ProductID" FROM "price" WHERE "price"."amount" >= $1 AND ("price"."product_id" = $2) GROUP BY "price"."id", "price"."amount", "price"."product_id" ORDER BY "price"."id" ASC) AS "Price" ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q ), 'Facets', jsonb_build_object( 'Price.ProductID', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT "Price"."ProductID" AS "Value", count(*) AS "Count" FROM ( SELECT "price"."id" AS "ID", "price"."amount" AS "Amount", "price"."product_id" AS "ProductID" FROM "price" WHERE "price"."amount" >= $3 AND (TRUE) GROUP BY "price"."id", "price"."amount", "price"."product_id" ) AS "Price" GROUP BY "Price"."ProductID" ORDER BY "Count" DESC, "Value" ASC ) q ) ), 'Histograms', jsonb_build_object( 'Price.Amount', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT floor("Price"."Amount"::float8 / 10) * 10 AS "From", floor("Price"."Amount"::float8 / 10) * 10 + 10 AS "To", count(*) AS "Count" FROM ( SELECT "price"."id" AS "ID", "price"."product_id" AS "ProductID", "price"."amount" AS "Amount" FROM "price" WHERE ("price"."product_id" = $4) GROUP BY "price"."id", "price"."product_id", "price"."amount" ) AS "Price" WHERE "Price"."Amount" IS NOT NULL GROUP BY floor("Price"."Amount"::float8 / 10) * 10 ORDER BY "From" ASC ) q ) ) )`,
+		},
+		{
+			Where:    "(OR,Price.ProductID|=|4,Price.Amount|>=|10)",
+			Expected: `SELECT jsonb_build_object( 'Data', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Price" ) AS "Price" FROM ( SELECT "price"."id" AS "ID", "price"."product_id" AS "ProductID", "price"."amount" AS "Amount" FROM "price" WHERE ("price"."product_id" = $1 OR "price"."amount" >= $2) GROUP BY "price"."id", "price"."product_id", "price"."amount" ORDER BY "price"."id" ASC) AS "Price" ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q ), 'Facets', jsonb_build_object( 'Price.ProductID', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT "Price"."ProductID" AS "Value", count(*) AS "Count" FROM ( SELECT "price"."id" AS "ID", "price"."amount" AS "Amount", "price"."product_id" AS "ProductID" FROM "price" WHERE (TRUE OR "price"."amount" >= $3) GROUP BY "price"."id", "price"."amount", "price"."product_id" ) AS "Price" GROUP BY "Price"."ProductID" ORDER BY "Count" DESC, "Value" ASC ) q ) ), 'Histograms', jsonb_build_object( 'Price.Amount', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT floor("Price"."Amount"::float8 / 10) * 10 AS "From", floor("Price"."Amount"::float8 / 10) * 10 + 10 AS "To", count(*) AS "Count" FROM ( SELECT "price"."id" AS "ID", "price"."product_id" AS "ProductID", "price"."amount" AS "Amount" FROM "price" WHERE ("price"."product_id" = $4 OR TRUE) GROUP BY "price"."id", "price"."product_id", "price"."amount" ) AS "Price" WHERE "Price"."Amount" IS NOT NULL GROUP BY floor("Price"."Amount"::float8 / 10) * 10 ORDER BY "From" ASC ) q ) ) )`,
+		},
+		{
+			Where:    "Price.ID|>|1,(AND,Price.ProductID|=|4,Price.Amount|>=|10)",
+			Expected: `SELECT jsonb_build_object( 'Data', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Price" ) AS "Price" FROM ( SELECT "price"."id" AS "ID", "price"."product_id" AS "ProductID", "price"."amount" AS "Amount" FROM "price" WHERE "price"."id" > $1 AND ("price"."product_id" = $2 AND "price"."amount" >= $3) GROUP BY "price"."id", "price"."product_id", "price"."amount" ORDER BY "price"."id" ASC) AS "Price" ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q ), 'Facets', jsonb_build_object( 'Price.ProductID', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT "Price"."ProductID" AS "Value", count(*) AS "Count" FROM ( SELECT "price"."id" AS "ID", "price"."amount" AS "Amount", "price"."product_id" AS "ProductID" FROM "price" WHERE "price"."id" > $4 AND ("price"."amount" >= $5) GROUP BY "price"."id", "price"."amount", "price"."product_id" ) AS "Price" GROUP BY "Price"."ProductID" ORDER BY "Count" DESC, "Value" ASC ) q ) ), 'Histograms', jsonb_build_object( 'Price.Amount', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT floor("Price"."Amount"::float8 / 10) * 10 AS "From", floor("Price"."Amount"::float8 / 10) * 10 + 10 AS "To", count(*) AS "Count" FROM ( SELECT "price"."id" AS "ID", "price"."product_id" AS "ProductID", "price"."amount" AS "Amount" FROM "price" WHERE "price"."id" > $6 AND ("price"."product_id" = $7) GROUP BY "price"."id", "price"."product_id", "price"."amount" ) AS "Price" WHERE "Price"."Amount" IS NOT NULL GROUP BY floor("Price"."Amount"::float8 / 10) * 10 ORDER BY "From" ASC ) q ) ) )`,
+		},
+	}
+
+	for _, te := range test {
+		li := New(context.TODO(), &Filters{Where: te.Where}).
+			WithFacets("Price.ProductID").
+			WithHistogram("Price.Amount", HistogramWidth(10))
+
+		err := li.FromSource(make([]PriceList, 0))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		sqlQuery, _ := li.SQL()
+		if sqlQuery != te.Expected {
+			t.Errorf("%s expected:\n%s\ngot:\n%s", te.Where, te.Expected, sqlQuery)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package liqu
 
 import (
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/url"
@@ -13,10 +14,15 @@ type (
 		PerPage       int
 		totalResults  int
 		totalPages    int
+		facets        map[string][]Facet
+		histograms    map[string]json.RawMessage
+		totals        map[string]interface{}
 		DisablePaging bool
 		Where         string
 		OrderBy       string
 		Select        string
+		Aggregate     string
+		GroupBy       string
 		PushUrl       bool
 	}
 
@@ -37,6 +43,11 @@ func (f *Filters) TotalPages() int {
 	return f.totalPages
 }
 
+// Facets returns the counts of the facets requested with WithFacets, keyed by their field
+func (f *Filters) Facets() map[string][]Facet {
+	return f.facets
+}
+
 func (f *Filters) FirstOnPage() int {
 	if f.Page == 1 {
 		return 1
@@ -160,6 +171,14 @@ func (f *Filters) params(query url.Values) template.URL {
 		query.Set("select", f.Select)
 	}
 
+	if strings.TrimSpace(f.Aggregate) != "" {
+		query.Set("agg", f.Aggregate)
+	}
+
+	if strings.TrimSpace(f.GroupBy) != "" {
+		query.Set("group_by", f.GroupBy)
+	}
+
 	if f.PushUrl {
 		query.Set("push_url", "true")
 	} else {
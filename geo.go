@@ -0,0 +1,165 @@
+package liqu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PostGIS expects geometry or geography point columns in SRID 4326.
+	PostGIS GeoBackend = "postgis"
+	// EarthDistance expects point(lng, lat) columns and uses the <@> operator of the earthdistance extension.
+	EarthDistance GeoBackend = "earthdistance"
+
+	kilometersPerMile = 1.609344
+)
+
+type (
+	// GeoBackend decides which extension the geospatial operators are rendered for.
+	GeoBackend string
+
+	geoPoint struct {
+		lat float64
+		lng float64
+	}
+)
+
+// DefaultGeoBackend is used by every Liqu instance that did not set one through WithGeoBackend.
+var DefaultGeoBackend = PostGIS
+
+// WithGeoBackend sets the extension the geospatial operators and distance ordering are rendered for.
+func (l *Liqu) WithGeoBackend(backend GeoBackend) *Liqu {
+	l.geo = backend
+
+	return l
+}
+
+func (l *Liqu) geoBackend() GeoBackend {
+	if l.geo != "" {
+		return l.geo
+	}
+
+	return DefaultGeoBackend
+}
+
+// parseCoordinates parses the float values of a geospatial filter, like `52.37--4.89--5`
+func parseCoordinates(val interface{}, count int) ([]float64, error) {
+	var values []string
+	switch v := val.(type) {
+	case string:
+		values = strings.Split(v, "--")
+	case []string:
+		values = v
+	}
+
+	if len(values) != count {
+		return nil, fmt.Errorf("expected %d values, got %d", count, len(values))
+	}
+
+	out := make([]float64, count)
+	for i, v := range values {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coordinate %s", v)
+		}
+
+		out[i] = f
+	}
+
+	return out, nil
+}
+
+func newGeoPoint(lat, lng float64) (geoPoint, error) {
+	if lat < -90 || lat > 90 {
+		return geoPoint{}, fmt.Errorf("invalid latitude %v", lat)
+	}
+
+	if lng < -180 || lng > 180 {
+		return geoPoint{}, fmt.Errorf("invalid longitude %v", lng)
+	}
+
+	return geoPoint{lat: lat, lng: lng}, nil
+}
+
+// parseGeoPoint parses the reference point of a distance order, like `52.37--4.89`
+func parseGeoPoint(value string) (geoPoint, error) {
+	c, err := parseCoordinates(value, 2)
+	if err != nil {
+		return geoPoint{}, err
+	}
+
+	return newGeoPoint(c[0], c[1])
+}
+
+// geoCondition adds a Within (`lat--lng--km`) or BBox (`minLng--minLat--maxLng--maxLat`) condition on a point column.
+func (l *Liqu) geoCondition(cb *ConditionBuilder, outer Operator, column string, op Operator, val interface{}) error {
+	var condition string
+
+	switch op {
+	case Within:
+		c, err := parseCoordinates(val, 3)
+		if err != nil {
+			return err
+		}
+
+		p, err := newGeoPoint(c[0], c[1])
+		if err != nil {
+			return err
+		}
+
+		if c[2] < 0 {
+			return fmt.Errorf("invalid radius %v", c[2])
+		}
+
+		if l.geoBackend() == EarthDistance {
+			condition = fmt.Sprintf("(%s <@> point(%s, %s)) <= %s", column, cb.bind(p.lng), cb.bind(p.lat), cb.bind(c[2]/kilometersPerMile))
+		} else {
+			condition = fmt.Sprintf("ST_DWithin(%s::geography, ST_SetSRID(ST_MakePoint(%s, %s), 4326)::geography, %s)", column, cb.bind(p.lng), cb.bind(p.lat), cb.bind(c[2]*1000))
+		}
+	case BBox:
+		c, err := parseCoordinates(val, 4)
+		if err != nil {
+			return err
+		}
+
+		if _, err = newGeoPoint(c[1], c[0]); err != nil {
+			return err
+		}
+
+		if _, err = newGeoPoint(c[3], c[2]); err != nil {
+			return err
+		}
+
+		if l.geoBackend() == EarthDistance {
+			condition = fmt.Sprintf("%s <@ box(point(%s, %s), point(%s, %s))", column, cb.bind(c[0]), cb.bind(c[1]), cb.bind(c[2]), cb.bind(c[3]))
+		} else {
+			condition = fmt.Sprintf("%s::geometry && ST_MakeEnvelope(%s, %s, %s, %s, 4326)", column, cb.bind(c[0]), cb.bind(c[1]), cb.bind(c[2]), cb.bind(c[3]))
+		}
+	default:
+		return fmt.Errorf("invalid geospatial operator %s", op)
+	}
+
+	if outer == And {
+		cb.AndRaw(condition)
+	} else {
+		cb.OrRaw(condition)
+	}
+
+	return nil
+}
+
+// distance returns the expression for the distance between the column and the point. the coordinates are parsed
+// floats, so they are inlined, which keeps the expression usable in both the inner and the wrapping query.
+func (l *Liqu) distance(column string, p geoPoint) string {
+	var (
+		lng = strconv.FormatFloat(p.lng, 'f', -1, 64)
+		lat = strconv.FormatFloat(p.lat, 'f', -1, 64)
+	)
+
+	if l.geoBackend() == EarthDistance {
+		return fmt.Sprintf("(%s <@> point(%s, %s))", column, lng, lat)
+	}
+
+	return fmt.Sprintf("ST_Distance(%s::geography, ST_SetSRID(ST_MakePoint(%s, %s), 4326)::geography)", column, lng, lat)
+}
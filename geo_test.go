@@ -0,0 +1,83 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+)
+
+type (
+	Store struct {
+		ID       int    `db:"id"`
+		Name     string `db:"name"`
+		Location string `db:"location"`
+	}
+
+	StoreList struct {
+		Store Store
+	}
+)
+
+func (m *Store) Table() string {
+	return "store"
+}
+
+func (m *Store) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
+func TestWithGeo(t *testing.T) {
+	test := []struct {
+		Backend  GeoBackend
+		Expected string
+		Params   []interface{}
+	}{
+		{
+			Backend:  PostGIS,
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Store" ) AS "Store" FROM ( SELECT "store"."id" AS "ID", "store"."location" AS "Location" FROM "store" WHERE ST_DWithin("store"."location"::geography, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3) AND "store"."location"::geometry && ST_MakeEnvelope($4, $5, $6, $7, 4326) GROUP BY "store"."location", "store"."id" ORDER BY ST_Distance("store"."location"::geography, ST_SetSRID(ST_MakePoint(4.89, 52.37), 4326)::geography) ASC, "store"."id" ASC) AS "Store" ORDER BY ST_Distance("Location"::geography, ST_SetSRID(ST_MakePoint(4.89, 52.37), 4326)::geography) ASC, "ID" ASC LIMIT 25 OFFSET 0 ) q`,
+			Params:   []interface{}{4.89, 52.37, 5000.0, 4.7, 52.2, 5.1, 52.5},
+		},
+		{
+			Backend:  EarthDistance,
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Store" ) AS "Store" FROM ( SELECT "store"."id" AS "ID", "store"."location" AS "Location" FROM "store" WHERE ("store"."location" <@> point($1, $2)) <= $3 AND "store"."location" <@ box(point($4, $5), point($6, $7)) GROUP BY "store"."location", "store"."id" ORDER BY ("store"."location" <@> point(4.89, 52.37)) ASC, "store"."id" ASC) AS "Store" ORDER BY ("Location" <@> point(4.89, 52.37)) ASC, "ID" ASC LIMIT 25 OFFSET 0 ) q`,
+			Params:   []interface{}{4.89, 52.37, 5 / kilometersPerMile, 4.7, 52.2, 5.1, 52.5},
+		},
+	}
+
+	for _, te := range test {
+		filters := &Filters{
+			Select:  "Store.ID,Store.Location",
+			Where:   "Store.Location|within|52.37--4.89--5,Store.Location|bbox|4.7--52.2--5.1--52.5",
+			OrderBy: "Store.Location|ASC|near:52.37--4.89",
+		}
+
+		li := New(context.TODO(), filters).
+			WithGeoBackend(te.Backend)
+
+		err := li.FromSource(make([]StoreList, 0))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		sqlQuery, sqlParams := li.SQL()
+		if sqlQuery != te.Expected {
+			t.Errorf("%s expected:\n%s\ngot:\n%s", te.Backend, te.Expected, sqlQuery)
+		}
+
+		if len(sqlParams) != len(te.Params) {
+			t.Errorf("%s expected %d params, got %d", te.Backend, len(te.Params), len(sqlParams))
+			continue
+		}
+
+		for k, v := range te.Params {
+			if sqlParams[k] != v {
+				t.Errorf("%s expected param %d to be %v, got %v", te.Backend, k, v, sqlParams[k])
+			}
+		}
+	}
+
+	li := New(context.TODO(), &Filters{Where: "Store.Location|within|95--4.89--5"})
+	if err := li.FromSource(make([]StoreList, 0)); err == nil {
+		t.Error("expected an error for an invalid latitude")
+	}
+}
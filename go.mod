@@ -1,3 +1,3 @@
 module github.com/donseba/liqu
 
-go 1.21.3
+go 1.23
@@ -38,6 +38,7 @@ func (l *Liqu) parseGroupBy(query string) error {
 	groupBys := strings.Split(query, ",")
 
 	for _, groupby := range groupBys {
+		groupby = strings.TrimSpace(groupby)
 
 		err := l.processGroupBy(groupby)
 		if err != nil {
@@ -63,15 +64,22 @@ func (l *Liqu) processGroupBy(col string) error {
 		model = l.tree.as
 		field = col
 	}
+	field = l.resolveField(model, field)
 
-	var ok bool
-	if column, ok = l.registry[model].fieldDatabase[field]; !ok {
+	if _, ok := l.registry[model].fieldDatabase[field]; !ok {
 		return fmt.Errorf("invalid group by field %s", col)
 	}
 
-	column = fmt.Sprintf(`"%s"."%s"`, l.registry[model].tableName, column)
+	column = l.fieldColumn(model, field)
 
-	l.registry[model].branch.groupBy.GroupBy(column)
+	branch := l.registry[model].branch
+	branch.groupBy.GroupBy(column)
+
+	// the aggregates of the root are computed in the wrapping query, so that is where their groups go as well
+	if branch == l.tree && len(branch.aggregateFields) > 0 {
+		l.processSelect(model, field)
+		branch.aggregateGroups = appendUnique(branch.aggregateGroups, field)
+	}
 
 	return nil
 }
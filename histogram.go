@@ -0,0 +1,229 @@
+package liqu
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// DateInterval is the calendar period a date histogram truncates its values to
+	DateInterval string
+
+	// Histogram describes the buckets a histogram counts the rows of the root in
+	Histogram struct {
+		width      float64
+		boundaries []float64
+		interval   DateInterval
+	}
+
+	// Bucket is a range of a histogram with the number of rows of the root in it, From is inclusive and To exclusive.
+	// the buckets below the first and above the last of explicit boundaries are open ended, their From or To is nil.
+	Bucket[T any] struct {
+		From  *T
+		To    *T
+		Count int
+	}
+)
+
+const (
+	IntervalDay   DateInterval = "day"
+	IntervalWeek  DateInterval = "week"
+	IntervalMonth DateInterval = "month"
+)
+
+// HistogramWidth counts the numeric values in buckets of a fixed width, starting at multiples of the width
+func HistogramWidth(width float64) Histogram {
+	return Histogram{width: width}
+}
+
+// HistogramBoundaries counts the numeric values in the buckets between the ascending boundaries
+func HistogramBoundaries(boundaries ...float64) Histogram {
+	return Histogram{boundaries: boundaries}
+}
+
+// HistogramDate counts the dates per calendar period, in the timezone of the context
+func HistogramDate(interval DateInterval) Histogram {
+	return Histogram{interval: interval}
+}
+
+// WithHistogram counts the rows of the root per bucket of the field next to the page, under all conditions of the
+// request except the ones on the field itself. the field has to be on the root or on a single valued relation of
+// the root. the buckets are read with Filters.Histogram or Filters.DateHistogram after PostProcess.
+func (l *Liqu) WithHistogram(column string, histogram Histogram) *Liqu {
+	l.histograms = append(l.histograms, &facet{
+		name:      strings.TrimSpace(column),
+		histogram: &histogram,
+	})
+
+	return l
+}
+
+// resolveHistogram validates the histogram against the type of its field, rows without a value are not counted
+func (l *Liqu) resolveHistogram() error {
+	var (
+		h         = l.facet.histogram
+		fieldType = l.registry[l.facet.model].fieldTypes[l.facet.field]
+	)
+
+	if h.interval != "" {
+		if !isTimeType(fieldType) {
+			return fmt.Errorf("invalid histogram %s, date intervals require a time field", l.facet.name)
+		}
+
+		switch h.interval {
+		case IntervalDay, IntervalWeek, IntervalMonth:
+		default:
+			return fmt.Errorf("invalid histogram %s, unknown interval %s", l.facet.name, h.interval)
+		}
+	} else {
+		if !isNumericType(fieldType) {
+			return fmt.Errorf("invalid histogram %s, buckets require a numeric field", l.facet.name)
+		}
+
+		if len(h.boundaries) == 0 && h.width <= 0 {
+			return fmt.Errorf("invalid histogram %s, the width has to be positive", l.facet.name)
+		}
+
+		for i := 1; i < len(h.boundaries); i++ {
+			if h.boundaries[i] <= h.boundaries[i-1] {
+				return fmt.Errorf("invalid histogram %s, the boundaries have to be ascending", l.facet.name)
+			}
+		}
+	}
+
+	if l.tree.anonymous {
+		l.tree.where.AndIsNotNull(l.facet.column)
+	} else {
+		l.tree.outerWhere.AndIsNotNull(l.facet.column)
+	}
+
+	return nil
+}
+
+// histogramSelects returns the columns of the rows of a histogram, the columns they are grouped on and their order
+func (l *Liqu) histogramSelects() ([]string, []string, string) {
+	var (
+		h      = l.facet.histogram
+		column = l.facet.column
+		from   string
+		to     string
+		group  string
+		order  = `"From" ASC`
+	)
+
+	switch {
+	case h.interval != "":
+		group = fmt.Sprintf(`date_trunc('%s', %s)`, h.interval, column)
+
+		// timestamps are truncated as seen from the timezone of the context
+		if loc := timezoneFromContext(l.ctx); loc != time.UTC {
+			group = fmt.Sprintf(`date_trunc('%s', %s, %s)`, h.interval, column, NewConditionBuilder().setLiqu(l).bind(loc.String()))
+		}
+
+		from = fmt.Sprintf(`(%s)::timestamptz`, group)
+		to = fmt.Sprintf(`(%s + interval '1 %s')::timestamptz`, group, h.interval)
+	case len(h.boundaries) > 0:
+		boundaries := make([]string, len(h.boundaries))
+		for k, v := range h.boundaries {
+			boundaries[k] = formatFloat(v)
+		}
+
+		array := fmt.Sprintf(`ARRAY[%s]::float8[]`, strings.Join(boundaries, ", "))
+
+		// width_bucket returns 0 below the first boundary, which is out of the bounds of the array and so NULL
+		group = fmt.Sprintf(`width_bucket(%s::float8, %s)`, column, array)
+		from = fmt.Sprintf(`(%s)[%s]`, array, group)
+		to = fmt.Sprintf(`(%s)[%s + 1]`, array, group)
+		order = `"From" ASC NULLS FIRST`
+	default:
+		width := formatFloat(h.width)
+
+		// an integer divided by an integer width truncates toward zero, which would put -5 in the bucket of 0
+		group = fmt.Sprintf(`floor(%s::float8 / %s) * %s`, column, width, width)
+		from = group
+		to = fmt.Sprintf(`%s + %s`, group, width)
+	}
+
+	return []string{
+		fmt.Sprintf(`%s AS "From"`, from),
+		fmt.Sprintf(`%s AS "To"`, to),
+		`count(*) AS "Count"`,
+	}, []string{group}, order
+}
+
+// Where returns the conditions matching the rows in the bucket on the column, in the format of the where parameter
+func (b Bucket[T]) Where(column string) string {
+	var conditions []string
+
+	if b.From != nil {
+		conditions = append(conditions, fmt.Sprintf("%s|>=|%s", column, formatBound(*b.From)))
+	}
+
+	if b.To != nil {
+		conditions = append(conditions, fmt.Sprintf("%s|<|%s", column, formatBound(*b.To)))
+	}
+
+	return strings.Join(conditions, ",")
+}
+
+// Histogram returns the buckets of the numeric histogram of the column, buckets of a date histogram fail to decode
+func (f *Filters) Histogram(column string) ([]Bucket[float64], error) {
+	return histogramBuckets[float64](column, f.histograms[column])
+}
+
+// DateHistogram returns the buckets of the date histogram of the column, buckets of a numeric histogram fail to decode
+func (f *Filters) DateHistogram(column string) ([]Bucket[time.Time], error) {
+	return histogramBuckets[time.Time](column, f.histograms[column])
+}
+
+func histogramBuckets[T any](column string, raw json.RawMessage) ([]Bucket[T], error) {
+	var buckets []Bucket[T]
+	if len(raw) == 0 {
+		return buckets, nil
+	}
+
+	err := json.Unmarshal(raw, &buckets)
+	if err != nil {
+		return nil, fmt.Errorf("[liqu] invalid buckets of histogram %s: %w", column, err)
+	}
+
+	return buckets, nil
+}
+
+func isNumericType(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatBound(v interface{}) string {
+	switch b := v.(type) {
+	case time.Time:
+		return b.Format(time.RFC3339)
+	case float64:
+		return formatFloat(b)
+	default:
+		return fmt.Sprintf("%v", b)
+	}
+}
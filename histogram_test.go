@@ -0,0 +1,132 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type (
+	PriceList struct {
+		Price Price
+	}
+)
+
+func TestWithHistogram(t *testing.T) {
+	filters := &Filters{
+		Where: "Price.ProductID|=|4,Price.Amount|>=|10",
+	}
+
+	li := New(context.TODO(), filters).
+		WithHistogram("Price.Amount", HistogramWidth(12.5)).
+		WithHistogram("Price.ID", HistogramBoundaries(10, 100, 1000))
+
+	err := li.FromSource(make([]PriceList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT jsonb_build_object( 'Data', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Price" ) AS "Price" FROM ( SELECT "price"."id" AS "ID", "price"."product_id" AS "ProductID", "price"."amount" AS "Amount" FROM "price" WHERE "price"."product_id" = $1 AND "price"."amount" >= $2 GROUP BY "price"."id", "price"."product_id", "price"."amount" ORDER BY "price"."id" ASC) AS "Price" ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q ), 'Histograms', jsonb_build_object( 'Price.Amount', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT floor("Price"."Amount"::float8 / 12.5) * 12.5 AS "From", floor("Price"."Amount"::float8 / 12.5) * 12.5 + 12.5 AS "To", count(*) AS "Count" FROM ( SELECT "price"."id" AS "ID", "price"."product_id" AS "ProductID", "price"."amount" AS "Amount" FROM "price" WHERE "price"."product_id" = $3 GROUP BY "price"."id", "price"."product_id", "price"."amount" ) AS "Price" WHERE "Price"."Amount" IS NOT NULL GROUP BY floor("Price"."Amount"::float8 / 12.5) * 12.5 ORDER BY "From" ASC ) q ), 'Price.ID', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT (ARRAY[10, 100, 1000]::float8[])[width_bucket("Price"."ID"::float8, ARRAY[10, 100, 1000]::float8[])] AS "From", (ARRAY[10, 100, 1000]::float8[])[width_bucket("Price"."ID"::float8, ARRAY[10, 100, 1000]::float8[]) + 1] AS "To", count(*) AS "Count" FROM ( SELECT "price"."id" AS "ID", "price"."product_id" AS "ProductID", "price"."amount" AS "Amount" FROM "price" WHERE "price"."product_id" = $4 AND "price"."amount" >= $5 GROUP BY "price"."id", "price"."product_id", "price"."amount" ) AS "Price" WHERE "Price"."ID" IS NOT NULL GROUP BY width_bucket("Price"."ID"::float8, ARRAY[10, 100, 1000]::float8[]) ORDER BY "From" ASC NULLS FIRST ) q ) ) )`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 5 {
+		t.Errorf("expected 5 params, got %d", len(sqlParams))
+	}
+
+	li.PostProcess(`{"Data": [], "Histograms": {"Price.Amount": [{"From": 12.5, "To": 25, "Count": 3}], "Price.ID": [{"From": null, "To": 10, "Count": 1}, {"From": 1000, "To": null, "Count": 2}]}}`)
+
+	amounts, err := li.Filters().Histogram("Price.Amount")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(amounts) != 1 || *amounts[0].From != 12.5 || *amounts[0].To != 25 || amounts[0].Count != 3 {
+		t.Errorf("expected the buckets of Price.Amount, got %+v", amounts)
+	}
+
+	if where := amounts[0].Where("Price.Amount"); where != "Price.Amount|>=|12.5,Price.Amount|<|25" {
+		t.Errorf("expected the conditions of the bucket, got %s", where)
+	}
+
+	ids, err := li.Filters().Histogram("Price.ID")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(ids) != 2 || ids[0].From != nil || ids[1].To != nil {
+		t.Errorf("expected open ended buckets of Price.ID, got %+v", ids)
+	}
+
+	if where := ids[0].Where("Price.ID"); where != "Price.ID|<|10" {
+		t.Errorf("expected the conditions of the bucket, got %s", where)
+	}
+
+	if _, err = li.Filters().DateHistogram("Price.Amount"); err == nil {
+		t.Error("expected an error reading numeric buckets as dates")
+	}
+
+	li = New(context.TODO(), nil).WithHistogram("Price.Amount", HistogramBoundaries(10, 5))
+	if err = li.FromSource(make([]PriceList, 0)); err == nil {
+		t.Error("expected an error for boundaries that are not ascending")
+	}
+
+	li = New(context.TODO(), nil).WithHistogram("Price.Amount", HistogramDate(IntervalMonth))
+	if err = li.FromSource(make([]PriceList, 0)); err == nil {
+		t.Error("expected an error for a date histogram on a numeric field")
+	}
+}
+
+func TestWithDateHistogram(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	filters := &Filters{
+		Where: "Event.Title|ILIKE|go",
+	}
+
+	ctx, err := ContextWithTimezone(context.TODO(), amsterdam)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	li := New(ctx, filters).
+		WithHistogram("Event.StartAt", HistogramDate(IntervalWeek))
+
+	err = li.FromSource(make([]EventList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT jsonb_build_object( 'Data', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Event" ) AS "Event" FROM ( SELECT "event"."id" AS "ID", "event"."title" AS "Title" FROM "event" WHERE "event"."title" ILIKE $1 GROUP BY "event"."id", "event"."title" ORDER BY "event"."id" ASC) AS "Event" ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q ), 'Histograms', jsonb_build_object( 'Event.StartAt', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT (date_trunc('week', "Event"."StartAt", $3))::timestamptz AS "From", (date_trunc('week', "Event"."StartAt", $3) + interval '1 week')::timestamptz AS "To", count(*) AS "Count" FROM ( SELECT "event"."id" AS "ID", "event"."title" AS "Title", "event"."start_at" AS "StartAt" FROM "event" WHERE "event"."title" ILIKE $2 GROUP BY "event"."id", "event"."title", "event"."start_at" ) AS "Event" WHERE "Event"."StartAt" IS NOT NULL GROUP BY date_trunc('week', "Event"."StartAt", $3) ORDER BY "From" ASC ) q ) ) )`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 3 || sqlParams[2] != "Europe/Amsterdam" {
+		t.Errorf("expected the timezone as the last param, got %+v", sqlParams)
+	}
+
+	li.PostProcess(`{"Data": [], "Histograms": {"Event.StartAt": [{"From": "2024-01-01T00:00:00+01:00", "To": "2024-01-08T00:00:00+01:00", "Count": 4}]}}`)
+
+	weeks, err := li.Filters().DateHistogram("Event.StartAt")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(weeks) != 1 || !weeks[0].From.Equal(time.Date(2024, time.January, 1, 0, 0, 0, 0, amsterdam)) {
+		t.Errorf("expected the buckets of Event.StartAt, got %+v", weeks)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -25,7 +26,14 @@ type (
 		PrimaryKeys() []string
 	}
 
+	// Expressions can be implemented by a Source to whitelist SQL expressions that can be ordered on by their name.
+	// fields are referenced between braces, like `"TitleLower": "lower({Title})"`.
+	Expressions interface {
+		Expressions() map[string]string
+	}
+
 	Liqu struct {
+		ctx                context.Context
 		source             interface{}
 		sourceType         reflect.Type
 		sourceSlice        bool
@@ -37,17 +45,31 @@ type (
 		subQueries         []*SubQuery
 		cte                map[string]*Cte
 		cteBranchedQueries []*CteBranchedQuery
+		children           ChildrenMode
+		geo                GeoBackend
+		noTieBreaker       bool
+		facets             []string
+		histograms         []*facet
+		totals             []Total
+		rows               bool
+		unpaged            bool
+		facet              *facet
 
 		sqlQuery  string
 		sqlParams []interface{}
 	}
 
 	registry struct {
-		fieldTypes    map[string]reflect.Type
-		fieldDatabase map[string]string
-		fieldSearch   map[string]interface{}
-		tableName     string
-		branch        *branch
+		fieldOrder      []string
+		fieldTypes      map[string]reflect.Type
+		fieldDatabase   map[string]string
+		fieldNormalize  map[string]normalize
+		fieldExpression map[string]string
+		fieldWindow     map[string]bool
+		fieldJSON       map[string]string
+		fieldSearch     map[string]interface{}
+		tableName       string
+		branch          *branch
 	}
 )
 
@@ -68,7 +90,12 @@ func New(ctx context.Context, filters *Filters) *Liqu {
 		filters.PerPage = DefaultPerPage
 	}
 
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	return &Liqu{
+		ctx:        ctx,
 		registry:   make(map[string]registry, 0),
 		linkedCte:  make(map[string][]linkedCte, 0),
 		cte:        make(map[string]*Cte),
@@ -84,6 +111,21 @@ func (l *Liqu) WithDefaults(defaults *Defaults) *Liqu {
 	return l
 }
 
+// WithChildrenMode sets how searching on slice relations affects the returned children for this request,
+// a `children` tag on the relation takes precedence.
+func (l *Liqu) WithChildrenMode(mode ChildrenMode) *Liqu {
+	l.children = mode
+
+	return l
+}
+
+// WithoutTieBreaker stops appending the primary keys to the order of the root and of limited relations.
+func (l *Liqu) WithoutTieBreaker() *Liqu {
+	l.noTieBreaker = true
+
+	return l
+}
+
 func (l *Liqu) FromSource(source interface{}) error {
 	var (
 		sourceType  = reflect.ValueOf(source).Type()
@@ -102,6 +144,10 @@ func (l *Liqu) FromSource(source interface{}) error {
 		return errors.New("[liqu] source needs to have at least one field")
 	}
 
+	if !l.children.valid() {
+		return fmt.Errorf("[liqu] unknown children mode %s", l.children)
+	}
+
 	// set everything we know about the source
 	l.source = source
 	l.sourceType = sourceType
@@ -112,12 +158,12 @@ func (l *Liqu) FromSource(source interface{}) error {
 		return err
 	}
 
-	err = l.parseFilters()
+	err = l.parseSubQueries()
 	if err != nil {
 		return err
 	}
 
-	err = l.parseSubQueries()
+	err = l.parseFilters()
 	if err != nil {
 		return err
 	}
@@ -127,6 +173,11 @@ func (l *Liqu) FromSource(source interface{}) error {
 		return err
 	}
 
+	err = l.traverseFacets()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -151,6 +202,76 @@ func getRootElement(rt reflect.Type) (reflect.Type, bool) {
 	return rt, slice
 }
 
+// splitColumn splits `Model.Field` into its model and field, a column without a model belongs to the root.
+func (l *Liqu) splitColumn(col string) (string, string) {
+	if model, field, ok := strings.Cut(col, "."); ok {
+		return model, l.resolveField(model, field)
+	}
+
+	return l.tree.as, l.resolveField(l.tree.as, col)
+}
+
+// fieldColumn returns the column of the field qualified by its table, or the expression of a computed field.
+func (l *Liqu) fieldColumn(model, field string) string {
+	reg := l.registry[model]
+	if expression, ok := reg.fieldExpression[field]; ok {
+		if reg.fieldWindow[field] {
+			expression = l.expandWindow(model, expression)
+		}
+
+		return fmt.Sprintf("(%s)", expression)
+	}
+
+	return fmt.Sprintf(`"%s"."%s"`, reg.tableName, reg.fieldDatabase[field])
+}
+
+// groupByField groups the branch on the field, a window field is computed after grouping,
+// so the branch is grouped on the fields it is computed over instead.
+func (l *Liqu) groupByField(branch *branch, field string) {
+	if !l.registry[branch.as].fieldWindow[field] {
+		branch.groupBy.GroupBy(l.fieldColumn(branch.as, field))
+		return
+	}
+
+	for _, f := range l.windowFields(branch.as, field) {
+		branch.groupBy.GroupBy(l.fieldColumn(branch.as, f))
+	}
+}
+
+// fieldKey returns the key of the field in the output, the name in its json tag or the field name itself.
+func (l *Liqu) fieldKey(model, field string) string {
+	if key, ok := l.registry[model].fieldJSON[field]; ok && key != "-" {
+		return key
+	}
+
+	return field
+}
+
+// fieldHidden reports whether the field is never returned, as it is tagged with json:"-"
+func (l *Liqu) fieldHidden(model, field string) bool {
+	return l.registry[model].fieldJSON[field] == "-"
+}
+
+// resolveField accepts the json name of a field where the field name is expected, the field name takes precedence.
+func (l *Liqu) resolveField(model, field string) string {
+	reg, ok := l.registry[model]
+	if !ok {
+		return field
+	}
+
+	if _, ok = reg.fieldDatabase[field]; ok {
+		return field
+	}
+
+	for _, f := range reg.fieldOrder {
+		if key, ok := reg.fieldJSON[f]; ok && key == field && key != "-" {
+			return f
+		}
+	}
+
+	return field
+}
+
 func Debug(v ...interface{}) {
 	fmt.Println("-------------")
 
@@ -187,7 +308,7 @@ func (l *Liqu) PostProcess(pp string) string {
 	l.filters.totalResults = count
 	l.filters.totalPages = int(math.Ceil(float64(l.filters.totalResults) / float64(l.filters.PerPage)))
 
-	return pp
+	return l.postProcessFacets(pp)
 }
 
 func ParseUrlValuesToFilters(values url.Values) (*Filters, error) {
@@ -202,6 +323,34 @@ func ParseUrlValuesToFilters(values url.Values) (*Filters, error) {
 			filters.Select = selectQuery[0]
 		}
 	}
+
+	// sparse fieldsets like fields[Author]=Name,Email are folded into the select
+	var fieldsets []string
+	for k := range values {
+		if strings.HasPrefix(k, "fields[") && strings.HasSuffix(k, "]") {
+			fieldsets = append(fieldsets, k)
+		}
+	}
+	sort.Strings(fieldsets)
+
+	for _, k := range fieldsets {
+		model := strings.TrimSuffix(strings.TrimPrefix(k, "fields["), "]")
+		if len(values[k]) == 0 || model == "" {
+			continue
+		}
+
+		for _, field := range strings.Split(values[k][0], ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+
+			if filters.Select != "" {
+				filters.Select += ","
+			}
+
+			filters.Select += fmt.Sprintf("%s.%s", model, field)
+		}
+	}
 	if whereQuery, ok := values["where"]; ok {
 		if len(whereQuery) > 0 {
 			filters.Where = whereQuery[0]
@@ -214,6 +363,18 @@ func ParseUrlValuesToFilters(values url.Values) (*Filters, error) {
 		}
 	}
 
+	if aggQuery, ok := values["agg"]; ok {
+		if len(aggQuery) > 0 {
+			filters.Aggregate = aggQuery[0]
+		}
+	}
+
+	if groupByQuery, ok := values["group_by"]; ok {
+		if len(groupByQuery) > 0 {
+			filters.GroupBy = groupByQuery[0]
+		}
+	}
+
 	if pageQuery, ok := values["page"]; ok {
 		if len(pageQuery) > 0 {
 			pageInt, err := strconv.Atoi(pageQuery[0])
@@ -255,22 +416,68 @@ func ParseUrlValuesToFilters(values url.Values) (*Filters, error) {
 
 func (l *Liqu) parseFilters() error {
 	var (
-		where string
-		order string
-		sel   string
+		where   string
+		order   string
+		sel     string
+		agg     string
+		groupBy string
 	)
 	if l.filters != nil {
 		where = l.filters.Where
 		order = l.filters.OrderBy
 		sel = l.filters.Select
+		agg = l.filters.Aggregate
+		groupBy = l.filters.GroupBy
+	}
+
+	err := l.processRelationAggregates()
+	if err != nil {
+		return err
+	}
+
+	err = l.processReport()
+	if err != nil {
+		return err
 	}
 
-	err := l.processDefaults()
+	err = l.resolveFacet()
 	if err != nil {
 		return err
 	}
 
-	err = l.parseNestedConditions(where, l.tree.where, And)
+	err = l.processDefaults()
+	if err != nil {
+		return err
+	}
+
+	// the requested aggregates go before the conditions and orders, so these can use their aliases
+	err = l.parseAggregates(agg)
+	if err != nil {
+		return err
+	}
+
+	// the allowed group bys only restrict the request, the group_by tags of the source are not checked
+	for _, v := range strings.Split(groupBy, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+
+		if model, field := l.splitColumn(v); !l.groupByAllowed(model, field) {
+			return fmt.Errorf("invalid group by field %s", v)
+		}
+	}
+
+	err = l.parseGroupBy(groupBy)
+	if err != nil {
+		return err
+	}
+
+	err = l.parseNestedConditions(where, And)
+	if err != nil {
+		return err
+	}
+
+	err = l.processDefaultOrders(order, true)
 	if err != nil {
 		return err
 	}
@@ -280,6 +487,11 @@ func (l *Liqu) parseFilters() error {
 		return err
 	}
 
+	err = l.processDefaultOrders(order, false)
+	if err != nil {
+		return err
+	}
+
 	err = l.parseSelect(sel, true)
 	if err != nil {
 		return err
@@ -2,6 +2,8 @@ package liqu
 
 import (
 	"context"
+	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -34,6 +36,54 @@ type (
 		Name string `db:"name"`
 	}
 
+	Company struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+
+	ProjectCompany struct {
+		Project Project
+
+		Company Company `related:"Company.ID=Project.CompanyID" join:"left"`
+	}
+
+	TreeLimited struct {
+		Project Project
+
+		ProjectTags []ProjectTag `related:"ProjectTags.ProjectID=Project.ID" join:"left" limit:"5" order_by:"ProjectTags.TagID|DESC"`
+	}
+
+	Person struct {
+		ID        int    `db:"id"`
+		FirstName string `db:"first_name"`
+		LastName  string `db:"last_name"`
+		FullName  string `db:"-" liqu:"expr:first_name || ' ' || last_name"`
+	}
+
+	PersonList struct {
+		Person Person
+	}
+
+	Article struct {
+		ID       int    `db:"id" json:"id"`
+		AuthorID int    `db:"author_id" json:"-"`
+		Title    string `db:"title" json:"title,omitempty"`
+		Body     string `db:"body"`
+
+		CategoryID int `db:"category_id" json:"category_id"`
+	}
+
+	Author struct {
+		ID   int    `db:"id" json:"id"`
+		Name string `db:"name" json:"name"`
+	}
+
+	ArticleList struct {
+		Article Article `json:"article"`
+
+		Author Author `related:"Author.ID=Article.AuthorID" join:"left" json:"author"`
+	}
+
 	Tree struct {
 		Project Project
 
@@ -49,6 +99,44 @@ func (m *Project) PrimaryKeys() []string {
 	return []string{"ID"}
 }
 
+func (m *Project) Expressions() map[string]string {
+	return map[string]string{
+		"NameLower": "lower({Name})",
+	}
+}
+
+func (m *Person) Table() string {
+	return "person"
+}
+
+func (m *Person) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
+func (m *Article) Table() string {
+	return "article"
+}
+
+func (m *Article) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
+func (m *Author) Table() string {
+	return "author"
+}
+
+func (m *Author) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
+func (m *Company) Table() string {
+	return "company"
+}
+
+func (m *Company) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
 func (m *Tag) Table() string {
 	return "tag"
 }
@@ -86,7 +174,7 @@ func TestNew(t *testing.T) {
 
 	sql, params := li.SQL()
 
-	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."id" AS "ID" FROM "project" GROUP BY "project"."id" ) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'TagID', "project_tag"."id_tag", 'ProjectID', "project_tag"."id_project", 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'TagID', "project_tag"."id_tag", 'ProjectID', "project_tag"."id_project", 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true LIMIT 25 OFFSET 0 ) q`
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."id" AS "ID" FROM "project" GROUP BY "project"."id" ORDER BY "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
 
 	if sql != expected {
 		t.Errorf("expected:\n%s\ngot:\n%s", expected, sql)
@@ -190,7 +278,7 @@ func TestWithWhere(t *testing.T) {
 
 	sqlQuery, sqlParams := li.SQL()
 
-	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."name" AS "Name", "project"."id" AS "ID", "project"."company_id" AS "CompanyID", "project"."description" AS "Description", "project"."volume" AS "Volume" FROM "project" WHERE "project"."company_id" = $1 AND "project"."name" = $2 GROUP BY "project"."name", "project"."id", "project"."company_id", "project"."description", "project"."volume" ORDER BY "project"."name" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'TagID', "project_tag"."id_tag", 'ProjectID', "project_tag"."id_project", 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'TagID', "project_tag"."id_tag", 'ProjectID', "project_tag"."id_project", 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) ORDER BY "tag"."name" DESC ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "Name" ASC LIMIT 25 OFFSET 0 ) q`
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."name" AS "Name", "project"."id" AS "ID", "project"."company_id" AS "CompanyID", "project"."description" AS "Description", "project"."volume" AS "Volume" FROM "project" WHERE "project"."company_id" = $1 AND "project"."name" = $2 GROUP BY "project"."name", "project"."id", "project"."company_id", "project"."description", "project"."volume" ORDER BY "project"."name" ASC, "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) ORDER BY "tag"."name" DESC ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "Name" ASC, "ID" ASC LIMIT 25 OFFSET 0 ) q`
 	if sqlQuery != expected {
 		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
 	}
@@ -226,7 +314,7 @@ func TestWithSubQuery(t *testing.T) {
 
 	sqlQuery, sqlParams := li.SQL()
 
-	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project" FROM ( SELECT "project"."name" AS "Name", "project"."id" AS "ID", "project"."company_id" AS "CompanyID", "project"."description" AS "Description", (SELECT SUM(volume) FROM "project_time_entry" WHERE project_time_entry.id_project="project"."id") AS "Volume" FROM "project" GROUP BY "project"."name", "project"."id", "project"."company_id", "project"."description" ORDER BY "project"."name" ASC) AS "Project" ORDER BY "Name" ASC LIMIT 25 OFFSET 0 ) q`
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project" FROM ( SELECT "project"."name" AS "Name", "project"."id" AS "ID", "project"."company_id" AS "CompanyID", "project"."description" AS "Description", (SELECT SUM(volume) FROM "project_time_entry" WHERE project_time_entry.id_project="project"."id") AS "Volume" FROM "project" GROUP BY "project"."name", "project"."id", "project"."company_id", "project"."description" ORDER BY "project"."name" ASC, "project"."id" ASC) AS "Project" ORDER BY "Name" ASC, "ID" ASC LIMIT 25 OFFSET 0 ) q`
 
 	if len(sqlQuery) != len(expected) {
 		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
@@ -266,7 +354,7 @@ func TestWithCTE(t *testing.T) {
 
 	sqlQuery, sqlParams := li.SQL()
 
-	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."name" AS "Name", "project"."id" AS "ID" FROM "project" GROUP BY "project"."name", "project"."id" ORDER BY "project"."name" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'TagID', "project_tag"."id_tag", 'ProjectID', "project_tag"."id_project", 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'TagID', "project_tag"."id_tag", 'ProjectID', "project_tag"."id_project", 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "Name" ASC LIMIT 25 OFFSET 0 ) q`
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."name" AS "Name", "project"."id" AS "ID" FROM "project" GROUP BY "project"."name", "project"."id" ORDER BY "project"."name" ASC, "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "Name" ASC, "ID" ASC LIMIT 25 OFFSET 0 ) q`
 
 	if sqlQuery != expected {
 		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
@@ -276,3 +364,659 @@ func TestWithCTE(t *testing.T) {
 		t.Errorf("expected 0 params, got %d", len(sqlParams))
 	}
 }
+
+func TestWithHaving(t *testing.T) {
+	filters := &Filters{
+		Where:   "Project.TotalVolume|>|100",
+		OrderBy: "Project.TotalVolume|DESC",
+	}
+
+	def := NewDefaults().
+		Sum("Project", "Volume", "TotalVolume")
+
+	li := New(context.TODO(), filters).WithDefaults(def)
+
+	err := li.FromSource(make([]Single, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, SUM("Project"."Volume") AS "TotalVolume" FROM ( SELECT "project"."id" AS "ID", "project"."volume" AS "Volume" FROM "project" GROUP BY "project"."id", "project"."volume" ) AS "Project" HAVING SUM("Project"."Volume") > $1 ORDER BY "TotalVolume" DESC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 1 {
+		t.Errorf("expected 1 params, got %d", len(sqlParams))
+	}
+}
+
+func TestWithRequestedAggregate(t *testing.T) {
+	values := url.Values{
+		"agg":      []string{"sum|Project.Volume|TotalVolume,count|Project.ID"},
+		"group_by": []string{"Project.CompanyID"},
+		"order_by": []string{"Project.CompanyID|ASC"},
+		"where":    []string{"Project.TotalVolume|>|100"},
+	}
+
+	filters, err := ParseUrlValuesToFilters(values)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	def := NewDefaults().
+		AllowAggregate("Project.Volume", AggSum).
+		AllowAggregate("ID").
+		AllowGroupBy("CompanyID")
+
+	li := New(context.TODO(), filters).WithDefaults(def)
+
+	err = li.FromSource(make([]Single, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, "Project"."CompanyID" AS "CompanyID", SUM("Project"."Volume") AS "TotalVolume", COUNT("Project"."ID") AS "CountID" FROM ( SELECT "project"."id" AS "ID", "project"."volume" AS "Volume", "project"."company_id" AS "CompanyID" FROM "project" GROUP BY "project"."company_id", "project"."id", "project"."volume" ) AS "Project" GROUP BY "Project"."CompanyID" HAVING SUM("Project"."Volume") > $1 ORDER BY "CompanyID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	filters, _ = ParseUrlValuesToFilters(url.Values{"agg": []string{"avg|Project.Volume"}})
+
+	li = New(context.TODO(), filters).WithDefaults(def)
+	if err = li.FromSource(make([]Single, 0)); err == nil {
+		t.Error("expected an error on an aggregate that is not allowed")
+	}
+
+	filters, _ = ParseUrlValuesToFilters(url.Values{"agg": []string{"sum|Project.Volume"}, "group_by": []string{"Project.Name"}})
+
+	li = New(context.TODO(), filters).WithDefaults(def)
+	if err = li.FromSource(make([]Single, 0)); err == nil {
+		t.Error("expected an error on a group by that is not allowed")
+	}
+}
+
+func TestWithGroupByTag(t *testing.T) {
+	type ProjectCompanyGrouped struct {
+		Project Project
+		Company Company `related:"Company.ID=Project.CompanyID" join:"left" group_by:"Company.Name"`
+	}
+
+	// the group_by tag of the source is not restricted by the group bys allowed for the request
+	li := New(context.TODO(), &Filters{Where: "Project.ID|>|1"})
+
+	err := li.FromSource(make([]ProjectCompanyGrouped, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if groups := li.registry["Company"].branch.groupBy.Build(); groups != `"company"."name", "company"."id"` {
+		t.Errorf("expected the group by of the tag, got %s", groups)
+	}
+}
+
+func TestWithRelationHaving(t *testing.T) {
+	filters := &Filters{
+		Where:   "ProjectTags.TagCount|>|3",
+		OrderBy: "ProjectTags.TagCount|DESC",
+	}
+
+	def := NewDefaults().
+		Count("ProjectTags", "TagID", "TagCount")
+
+	li := New(context.TODO(), filters).WithDefaults(def)
+
+	err := li.FromSource(make([]Tree, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."id" AS "ID" FROM "project" WHERE (SELECT COUNT("project_tag"."id_tag") AS "TagCount" FROM "project_tag" WHERE "project_tag"."id_project" = "project"."id") > $1 GROUP BY "project"."id" ORDER BY "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY (SELECT COUNT("project_tag"."id_tag") AS "TagCount" FROM "project_tag" WHERE "project_tag"."id_project" = "Project"."ID") DESC, "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 1 {
+		t.Errorf("expected 1 params, got %d", len(sqlParams))
+	}
+
+	def = NewDefaults().
+		Count("Tags", "ID", "TagTotal")
+
+	li = New(context.TODO(), &Filters{Where: "Tags.TagTotal|>|3"}).WithDefaults(def)
+	if err = li.FromSource(make([]Tree, 0)); err == nil {
+		t.Error("expected an error for an aggregate of a nested relation")
+	}
+}
+
+func TestWithSubQueryWhere(t *testing.T) {
+	filters := &Filters{
+		Where:   "Project.Volume|>|10",
+		OrderBy: "Project.Volume|DESC",
+	}
+
+	volumeSQ := NewSubQuery("Project", "Volume").
+		Relate("id_project", "ID").
+		Select("SUM(volume)").
+		From("project_time_entry")
+
+	li := New(context.TODO(), filters).
+		WithSubQuery(volumeSQ)
+
+	err := li.FromSource(make([]Single, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project" FROM ( SELECT (SELECT SUM(volume) FROM "project_time_entry" WHERE project_time_entry.id_project="project"."id") AS "Volume", "project"."id" AS "ID" FROM "project" WHERE (SELECT SUM(volume) FROM "project_time_entry" WHERE project_time_entry.id_project="project"."id") > $1 GROUP BY "project"."id" ORDER BY (SELECT SUM(volume) FROM "project_time_entry" WHERE project_time_entry.id_project="project"."id") DESC, "project"."id" ASC) AS "Project" ORDER BY "Volume" DESC, "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 1 {
+		t.Errorf("expected 1 params, got %d", len(sqlParams))
+	}
+}
+
+func TestWithWhereExists(t *testing.T) {
+	filters := &Filters{
+		Where: "ProjectTags.TagID|has|5--6,ProjectTags.TagID|hasnot|",
+	}
+
+	li := New(context.TODO(), filters)
+
+	err := li.FromSource(make([]Tree, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	// the relation keeps its LEFT join and returns all the tags of the matching projects
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."id" AS "ID" FROM "project" WHERE EXISTS (SELECT 1 FROM "project_tag" WHERE "project_tag"."id_project" = "project"."id" AND "project_tag"."id_tag" IN ($1, $2)) AND NOT EXISTS (SELECT 1 FROM "project_tag" WHERE "project_tag"."id_project" = "project"."id") GROUP BY "project"."id" ORDER BY "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 2 {
+		t.Errorf("expected 2 params, got %d", len(sqlParams))
+	}
+}
+
+func TestWithWhereDistinctFrom(t *testing.T) {
+	filters, err := ParseUrlValuesToFilters(url.Values{
+		"where": []string{`Project.Name|IS DISTINCT FROM|a,Project.Description|IS NOT DISTINCT FROM|\null,Project.CompanyID|IS DISTINCT FROM|\null`},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	li := New(context.TODO(), filters).WithoutTieBreaker()
+
+	err = li.FromSource(make([]Single, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project" FROM ( SELECT "project"."id" AS "ID", "project"."name" AS "Name", "project"."description" AS "Description", "project"."company_id" AS "CompanyID" FROM "project" WHERE "project"."name" IS DISTINCT FROM $1 AND "project"."description" IS NULL AND "project"."company_id" IS NOT NULL GROUP BY "project"."id", "project"."name", "project"."description", "project"."company_id" ) AS "Project" LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 1 {
+		t.Errorf("expected 1 param, got %d", len(sqlParams))
+	}
+
+	// a list would be compared as a row
+	for _, where := range []string{`Project.Name|IS DISTINCT FROM|a--b`, `Project.Name|IS NOT DISTINCT FROM|a--\null`} {
+		filters, _ = ParseUrlValuesToFilters(url.Values{"where": []string{where}})
+
+		li = New(context.TODO(), filters)
+		if err = li.FromSource(make([]Single, 0)); err == nil {
+			t.Errorf("expected an error on a list for %s", where)
+		}
+	}
+}
+
+func TestWithWhereGroups(t *testing.T) {
+	test := []struct {
+		Where    string
+		Defaults *Defaults
+		Source   interface{}
+		Expected string
+	}{
+		{
+			Where:    "Project.Name|=|a,(OR,Project.Volume|>|10,Project.Volume|IS NULL)",
+			Source:   make([]Single, 0),
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project" FROM ( SELECT "project"."id" AS "ID", "project"."name" AS "Name", "project"."volume" AS "Volume" FROM "project" WHERE "project"."name" = $1 AND ("project"."volume" > $2 OR "project"."volume" IS NULL) GROUP BY "project"."id", "project"."name", "project"."volume" ) AS "Project" LIMIT 25 OFFSET 0 ) q`,
+		},
+		{
+			Where:    "(OR,Project.Name|=|a,ProjectTags.TagID|has|5)",
+			Source:   make([]Tree, 0),
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."id" AS "ID", "project"."name" AS "Name" FROM "project" WHERE ("project"."name" = $1 OR EXISTS (SELECT 1 FROM "project_tag" WHERE "project_tag"."id_project" = "project"."id" AND "project_tag"."id_tag" = $2)) GROUP BY "project"."id", "project"."name" ) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true LIMIT 25 OFFSET 0 ) q`,
+		},
+		{
+			Where:    "(OR,Project.Name|=|a,ProjectTags.TagID|=|5)",
+			Source:   make([]Tree, 0),
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."id" AS "ID", "project"."name" AS "Name" FROM "project" WHERE ("project"."name" = $1) GROUP BY "project"."id", "project"."name" ) AS "Project" INNER JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE ("project_tag"."id_tag" = $2) AND id_project = "Project"."ID" ) AS "ProjectTags" ON true WHERE "ProjectTags" IS NOT NULL LIMIT 25 OFFSET 0 ) q`,
+		},
+		{
+			Where:    "Project.Name|=|a,(OR,Project.TotalVolume|>|100,Project.TotalVolume|<|5)",
+			Defaults: NewDefaults().Sum("Project", "Volume", "TotalVolume"),
+			Source:   make([]Single, 0),
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, SUM("Project"."Volume") AS "TotalVolume" FROM ( SELECT "project"."id" AS "ID", "project"."volume" AS "Volume", "project"."name" AS "Name" FROM "project" WHERE "project"."name" = $1 GROUP BY "project"."id", "project"."volume", "project"."name" ) AS "Project" HAVING (SUM("Project"."Volume") > $2 OR SUM("Project"."Volume") < $3) LIMIT 25 OFFSET 0 ) q`,
+		},
+	}
+
+	for _, te := range test {
+		li := New(context.TODO(), &Filters{Where: te.Where}).WithoutTieBreaker()
+		if te.Defaults != nil {
+			li.WithDefaults(te.Defaults)
+		}
+
+		err := li.FromSource(te.Source)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		sqlQuery, _ := li.SQL()
+		if sqlQuery != te.Expected {
+			t.Errorf("%s expected:\n%s\ngot:\n%s", te.Where, te.Expected, sqlQuery)
+		}
+	}
+}
+
+func TestWithChildrenModeAll(t *testing.T) {
+	filters := &Filters{
+		Where: "ProjectTags.TagID|>|5",
+	}
+
+	li := New(context.TODO(), filters).
+		WithChildrenMode(ChildrenAll)
+
+	err := li.FromSource(make([]Tree, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	// the projects are filtered on their tags, while every tag of a matching project is returned
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."id" AS "ID" FROM "project" WHERE EXISTS (SELECT 1 FROM "project_tag" WHERE "project_tag"."id_project" = "project"."id" AND "project_tag"."id_tag" > $1) GROUP BY "project"."id" ORDER BY "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 1 {
+		t.Errorf("expected 1 param, got %d", len(sqlParams))
+	}
+}
+
+func TestWithChildrenModeAllNested(t *testing.T) {
+	filters := &Filters{
+		Where: "Tags.Name|=|go",
+	}
+
+	li := New(context.TODO(), filters).
+		WithChildrenMode(ChildrenAll)
+
+	err := li.FromSource(make([]Tree, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	// the projects are filtered through their project tags on the tags, while every project tag and tag is returned
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."id" AS "ID" FROM "project" WHERE EXISTS (SELECT 1 FROM "project_tag" WHERE "project_tag"."id_project" = "project"."id" AND EXISTS (SELECT 1 FROM "tag" WHERE "tag"."id" = "project_tag"."id_tag" AND "tag"."name" = $1)) GROUP BY "project"."id" ORDER BY "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 1 {
+		t.Errorf("expected 1 param, got %d", len(sqlParams))
+	}
+}
+
+func TestWithChildrenModeInvalid(t *testing.T) {
+	li := New(context.TODO(), nil).
+		WithChildrenMode("some")
+
+	if err := li.FromSource(make([]Tree, 0)); err == nil {
+		t.Error("expected an error for an unknown children mode")
+	}
+
+	type TreeChildren struct {
+		Project     Project
+		ProjectTags []ProjectTag `related:"ProjectTags.ProjectID=Project.ID" join:"left" children:"any"`
+	}
+
+	li = New(context.TODO(), nil)
+	if err := li.FromSource(make([]TreeChildren, 0)); err == nil {
+		t.Error("expected an error for an unknown children tag")
+	}
+}
+
+func TestWithRelationOrder(t *testing.T) {
+	filters := &Filters{
+		Select:  "Project.ID,Project.CompanyID",
+		OrderBy: "Company.Name|ASC,Project.ID|DESC",
+	}
+
+	li := New(context.TODO(), filters)
+
+	err := li.FromSource(make([]ProjectCompany, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	// the company name is exposed by the lateral join, so the projects themselves are ordered on it
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "Company"."Company" AS "Company" FROM ( SELECT "project"."id" AS "ID", "project"."company_id" AS "CompanyID" FROM "project" GROUP BY "project"."id", "project"."company_id" ORDER BY "project"."id" DESC) AS "Project" LEFT JOIN LATERAL ( SELECT name, to_jsonb( jsonb_build_object( 'ID', "company"."id" ) ) AS "Company" FROM "company" WHERE id = "Project"."CompanyID" ) AS "Company" ON true ORDER BY "Company"."name" ASC, "ID" DESC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+}
+
+func TestWithOrderNulls(t *testing.T) {
+	filters := &Filters{
+		Select:  "Project.ID,Project.Name,Project.Volume",
+		OrderBy: "Project.Name|DESC|NULLSLAST",
+	}
+
+	def := NewDefaults().
+		OrderByNulls("Project.Volume", Asc, NullsFirst)
+
+	li := New(context.TODO(), filters).
+		WithDefaults(def)
+
+	err := li.FromSource(make([]Tree, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	// the null placement carries through to the order of the wrapping query
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."name" AS "Name", "project"."volume" AS "Volume", "project"."id" AS "ID" FROM "project" GROUP BY "project"."volume", "project"."name", "project"."id" ORDER BY "project"."volume" ASC NULLS FIRST, "project"."name" DESC NULLS LAST, "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "Volume" ASC NULLS FIRST, "Name" DESC NULLS LAST, "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	orders, err := ExtractOrders(`"project"."name" DESC NULLS LAST, "project"."id"`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(orders) != 2 || orders[0].Nulls != "NULLS LAST" || orders[1].Direction != "ASC" || orders[1].Nulls != "" {
+		t.Errorf("unexpected extracted orders %+v", orders)
+	}
+}
+
+func TestWithTieBreaker(t *testing.T) {
+	filters := &Filters{
+		Select:  "Project.ID,Project.Name",
+		OrderBy: "Project.Name|ASC",
+	}
+
+	li := New(context.TODO(), filters)
+
+	err := li.FromSource(make([]TreeLimited, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	// the tags are limited before they are aggregated, both lists are made deterministic by their primary keys
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."name" AS "Name", "project"."id" AS "ID" FROM "project" GROUP BY "project"."name", "project"."id" ORDER BY "project"."name" ASC, "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ORDER BY "project_tag"."id_tag" DESC, "project_tag"."id_project" ASC ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM ( SELECT * FROM "project_tag" WHERE id_project = "Project"."ID" ORDER BY "project_tag"."id_tag" DESC, "project_tag"."id_project" ASC LIMIT 5 OFFSET 0 ) AS "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true ) AS "ProjectTags" ON true ORDER BY "Name" ASC, "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	li = New(context.TODO(), filters).
+		WithoutTieBreaker()
+
+	err = li.FromSource(make([]TreeLimited, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ = li.SQL()
+
+	expected = `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."name" AS "Name", "project"."id" AS "ID" FROM "project" GROUP BY "project"."name", "project"."id" ORDER BY "project"."name" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ORDER BY "project_tag"."id_tag" DESC ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM ( SELECT * FROM "project_tag" WHERE id_project = "Project"."ID" ORDER BY "project_tag"."id_tag" DESC LIMIT 5 OFFSET 0 ) AS "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true ) AS "ProjectTags" ON true ORDER BY "Name" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	type ProjectNoteList struct {
+		ProjectNote ProjectNote
+	}
+
+	li = New(context.TODO(), nil)
+
+	err = li.FromSource(make([]ProjectNoteList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ = li.SQL()
+
+	// without an order the rows are ordered on the primary keys alone, which match the fields regardless of their case
+	expected = `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "ProjectNote" ) AS "ProjectNote" FROM ( SELECT "project_note"."id" AS "ID" FROM "project_note" GROUP BY "project_note"."id" ORDER BY "project_note"."id" ASC) AS "ProjectNote" ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+}
+
+func TestWithDefaultOrder(t *testing.T) {
+	test := []struct {
+		Merge    OrderMerge
+		Expected string
+	}{
+		{
+			Merge:    OrderAppend,
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Store" ) AS "Store" FROM ( SELECT "store"."location" AS "Location", "store"."name" AS "Name", "store"."id" AS "ID" FROM "store" WHERE "store"."id" > $1 AND "store"."id" < $2 GROUP BY "store"."name", "store"."location", "store"."id" ORDER BY "store"."name" ASC, "store"."location" ASC) AS "Store" ORDER BY "Name" ASC, "Location" ASC LIMIT 25 OFFSET 0 ) q`,
+		},
+		{
+			Merge:    OrderPrepend,
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Store" ) AS "Store" FROM ( SELECT "store"."name" AS "Name", "store"."location" AS "Location", "store"."id" AS "ID" FROM "store" WHERE "store"."id" > $1 AND "store"."id" < $2 GROUP BY "store"."location", "store"."name", "store"."id" ORDER BY "store"."location" ASC, "store"."name" ASC) AS "Store" ORDER BY "Location" ASC, "Name" ASC LIMIT 25 OFFSET 0 ) q`,
+		},
+		{
+			Merge:    OrderReplace,
+			Expected: `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Store" ) AS "Store" FROM ( SELECT "store"."location" AS "Location", "store"."id" AS "ID", "store"."name" AS "Name" FROM "store" WHERE "store"."id" > $1 AND "store"."id" < $2 GROUP BY "store"."location", "store"."id", "store"."name" ORDER BY "store"."location" ASC) AS "Store" ORDER BY "Location" ASC LIMIT 25 OFFSET 0 ) q`,
+		},
+	}
+
+	for _, te := range test {
+		def := NewDefaults().
+			OrderBy("Store.Name", Asc).
+			OrderBy("Store.Location", Desc).
+			OrderByMerge(te.Merge).
+			Where("Store.ID", GreaterThan, 10).
+			Where("Store.ID", LessThan, 100)
+
+		filters := &Filters{
+			Select:  "Store.ID,Store.Name,Store.Location",
+			OrderBy: "Store.Location|ASC",
+		}
+
+		li := New(context.TODO(), filters).
+			WithDefaults(def).
+			WithoutTieBreaker()
+
+		err := li.FromSource(make([]StoreList, 0))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		sqlQuery, _ := li.SQL()
+		if sqlQuery != te.Expected {
+			t.Errorf("%s expected:\n%s\ngot:\n%s", te.Merge, te.Expected, sqlQuery)
+		}
+	}
+}
+
+func TestWithOrderExpression(t *testing.T) {
+	filters := &Filters{
+		Select:  "Project.ID,Project.Name",
+		OrderBy: "Project.NameLower|DESC",
+	}
+
+	li := New(context.TODO(), filters).
+		WithoutTieBreaker()
+
+	err := li.FromSource(make([]ProjectCompany, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "Company"."Company" AS "Company" FROM ( SELECT "project"."id" AS "ID", "project"."name" AS "Name", "project"."company_id" AS "CompanyID" FROM "project" GROUP BY "project"."name", "project"."id", "project"."company_id" ORDER BY lower("project"."name") DESC) AS "Project" LEFT JOIN LATERAL ( SELECT to_jsonb( jsonb_build_object( 'ID', "company"."id" ) ) AS "Company" FROM "company" WHERE id = "Project"."CompanyID" ) AS "Company" ON true ORDER BY lower("Name") DESC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	li = New(context.TODO(), &Filters{OrderBy: "Project.upper(name)|DESC"})
+	if err = li.FromSource(make([]ProjectCompany, 0)); err == nil {
+		t.Error("expected an error ordering on an expression that is not whitelisted")
+	}
+}
+
+func TestWithSelectExclusion(t *testing.T) {
+	values := url.Values{}
+	values.Set("select", "Project.*,-Project.Description,-Project.Volume,-ProjectTags")
+	values.Set("fields[Project]", "Name")
+
+	filters, err := ParseUrlValuesToFilters(values)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if filters.Select != "Project.*,-Project.Description,-Project.Volume,-ProjectTags,Project.Name" {
+		t.Errorf("unexpected select %s", filters.Select)
+	}
+
+	li := New(context.TODO(), filters)
+
+	err = li.FromSource(make([]Tree, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	// the description and volume are excluded and the tags are not joined at all
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project" FROM ( SELECT "project"."id" AS "ID", "project"."company_id" AS "CompanyID", "project"."name" AS "Name" FROM "project" GROUP BY "project"."id", "project"."company_id", "project"."name" ORDER BY "project"."id" ASC) AS "Project" ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	li = New(context.TODO(), &Filters{Select: "Tags"})
+
+	err = li.FromSource(make([]Tree, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ = li.SQL()
+
+	// selecting a relation by name returns all of its fields
+	expected = `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project", "ProjectTags"."ProjectTags" AS "ProjectTags" FROM ( SELECT "project"."id" AS "ID" FROM "project" GROUP BY "project"."id" ORDER BY "project"."id" ASC) AS "Project" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'Tags', "Tags"."Tags" ) ) FILTER ( WHERE jsonb_build_object( 'Tags', "Tags"."Tags" ) IS NOT NULL ),'[]' ) AS "ProjectTags" FROM "project_tag" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) ) FILTER ( WHERE jsonb_build_object( 'ID', "tag"."id", 'Name', "tag"."name" ) IS NOT NULL ),'[]' ) AS "Tags" FROM "tag" WHERE id = "project_tag"."id_tag" ) AS "Tags" ON true WHERE id_project = "Project"."ID" ) AS "ProjectTags" ON true ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	li = New(context.TODO(), &Filters{Select: "-Tags", Where: "Tags.Name|=|Foo"})
+	if err = li.FromSource(make([]Tree, 0)); err == nil {
+		t.Error("expected an error excluding a searched relation")
+	}
+}
+
+func TestWithComputedField(t *testing.T) {
+	filters := &Filters{
+		Select:  "Person.FullName",
+		Where:   "Person.FullName|ILIKE|john",
+		OrderBy: "Person.FullName|ASC",
+	}
+
+	li := New(context.TODO(), filters).
+		WithoutTieBreaker()
+
+	err := li.FromSource(make([]PersonList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Person" ) AS "Person" FROM ( SELECT (first_name || ' ' || last_name) AS "FullName", "person"."id" AS "ID" FROM "person" WHERE (first_name || ' ' || last_name) ILIKE $1 GROUP BY (first_name || ' ' || last_name), "person"."id" ORDER BY (first_name || ' ' || last_name) ASC) AS "Person" ORDER BY "FullName" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	columns := WritableColumns(&Person{})
+	if strings.Join(columns, ",") != "id,first_name,last_name" {
+		t.Errorf("expected the computed field to be left out, got %v", columns)
+	}
+}
+
+func TestWithJSONKeys(t *testing.T) {
+	filters := &Filters{
+		Select:  "Article.title,Author.*",
+		Where:   "title|=|liqu",
+		OrderBy: "Article.title|DESC",
+	}
+
+	li := New(context.TODO(), filters).
+		WithoutTieBreaker()
+
+	err := li.FromSource(make([]ArticleList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( jsonb_build_object( 'id', "Article"."ID", 'title', "Article"."Title" ) ) AS "article", "Author"."Author" AS "author" FROM ( SELECT "article"."title" AS "Title", "article"."id" AS "ID", "article"."author_id" AS "AuthorID" FROM "article" WHERE "article"."title" = $1 GROUP BY "article"."title", "article"."id", "article"."author_id" ORDER BY "article"."title" DESC) AS "Article" LEFT JOIN LATERAL ( SELECT to_jsonb( jsonb_build_object( 'id', "author"."id", 'name', "author"."name" ) ) AS "Author" FROM "author" WHERE id = "Article"."AuthorID" ) AS "Author" ON true ORDER BY "Title" DESC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+}
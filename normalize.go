@@ -0,0 +1,89 @@
+package liqu
+
+import (
+	"fmt"
+)
+
+type (
+	// normalize describes how a field is compared and ordered, either accent- and case-insensitive
+	// through unaccent(lower(...)) or by applying a (nondeterministic) collation.
+	normalize struct {
+		unaccent  bool
+		collation string
+	}
+)
+
+func (n normalize) isZero() bool {
+	return !n.unaccent && n.collation == ""
+}
+
+// column wraps the column in the normalization
+func (n normalize) column(column string) string {
+	if n.unaccent {
+		column = fmt.Sprintf("unaccent(lower(%s))", column)
+	}
+
+	if n.collation != "" {
+		column = fmt.Sprintf(`%s COLLATE "%s"`, column, n.collation)
+	}
+
+	return column
+}
+
+// value returns the format the placeholders of a condition are wrapped in, so both sides compare the same way.
+func (n normalize) value() string {
+	if n.unaccent {
+		return "unaccent(lower(%s))"
+	}
+
+	return ""
+}
+
+func (n normalize) merge(o normalize) normalize {
+	if o.unaccent {
+		n.unaccent = true
+	}
+
+	if o.collation != "" {
+		n.collation = o.collation
+	}
+
+	return n
+}
+
+// normalizeFromTag reads the unaccent and collate options of the liqu tag
+func normalizeFromTag(options map[string]string) normalize {
+	var n normalize
+
+	if _, ok := options["unaccent"]; ok {
+		n.unaccent = true
+	}
+
+	if collation, ok := options["collate"]; ok {
+		n.collation = collation
+	}
+
+	return n
+}
+
+func (l *Liqu) applyNormalize(col string, n normalize) error {
+	model, field := l.splitColumn(col)
+
+	reg, ok := l.registry[model]
+	if !ok {
+		return fmt.Errorf("invalid normalize field %s", col)
+	}
+
+	if _, ok := reg.fieldDatabase[field]; !ok {
+		return fmt.Errorf("invalid normalize field %s", col)
+	}
+
+	if reg.fieldNormalize == nil {
+		reg.fieldNormalize = make(map[string]normalize)
+		l.registry[model] = reg
+	}
+
+	reg.fieldNormalize[field] = reg.fieldNormalize[field].merge(n)
+
+	return nil
+}
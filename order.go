@@ -3,17 +3,25 @@ package liqu
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 )
 
 const (
 	Asc  OrderDirection = "ASC"
 	Desc                = "DESC"
+
+	NullsFirst OrderNulls = "NULLS FIRST"
+	NullsLast  OrderNulls = "NULLS LAST"
 )
 
 type (
 	OrderDirection string
 
+	// OrderNulls places the NULL values before or after the other values, by default postgres
+	// puts them last when ordering ascending and first when ordering descending.
+	OrderNulls string
+
 	OrderBuilder struct {
 		orders []Order
 	}
@@ -21,6 +29,11 @@ type (
 	Order struct {
 		Column    string
 		Direction OrderDirection
+		Nulls     OrderNulls
+
+		// parent is the column as exposed to the wrapping query, normally the alias of the field.
+		parent    string
+		normalize normalize
 	}
 )
 
@@ -28,6 +41,26 @@ func (od OrderDirection) String() string {
 	return string(od)
 }
 
+func (on OrderNulls) String() string {
+	return string(on)
+}
+
+// modifier returns the form used in the order grammar, like `NULLSLAST`
+func (on OrderNulls) modifier() string {
+	return strings.ReplaceAll(string(on), " ", "")
+}
+
+// parseOrderNulls parses the `NULLSFIRST` and `NULLSLAST` order modifiers
+func parseOrderNulls(modifier string) (OrderNulls, bool) {
+	for _, n := range []OrderNulls{NullsFirst, NullsLast} {
+		if strings.EqualFold(modifier, n.modifier()) {
+			return n, true
+		}
+	}
+
+	return "", false
+}
+
 func NewOrderBuilder() *OrderBuilder {
 	return &OrderBuilder{
 		orders: []Order{},
@@ -43,9 +76,26 @@ func (ob *OrderBuilder) OrderBy(column string, direction OrderDirection) *OrderB
 	return ob
 }
 
+// OrderByNulls orders on the column with the NULL values placed first or last
+func (ob *OrderBuilder) OrderByNulls(column string, direction OrderDirection, nulls OrderNulls) *OrderBuilder {
+	ob.orders = append(ob.orders, Order{
+		Column:    column,
+		Direction: direction,
+		Nulls:     nulls,
+	})
+
+	return ob
+}
+
+func (ob *OrderBuilder) order(order Order) *OrderBuilder {
+	ob.orders = append(ob.orders, order)
+
+	return ob
+}
+
 func (ob *OrderBuilder) HasOrderBy(column string) bool {
 	for _, v := range ob.orders {
-		if v.Column == column {
+		if v.matches(column) {
 			return true
 		}
 	}
@@ -54,7 +104,7 @@ func (ob *OrderBuilder) HasOrderBy(column string) bool {
 }
 func (ob *OrderBuilder) Unset(column string) {
 	for k, v := range ob.orders {
-		if v.Column == column {
+		if v.matches(column) {
 			ob.orders = append(ob.orders[:k], ob.orders[k+1:]...)
 			return
 		}
@@ -66,19 +116,54 @@ func (ob *OrderBuilder) Unset(column string) {
 func (ob *OrderBuilder) Build() string {
 	parts := make([]string, 0)
 	for _, v := range ob.orders {
-		parts = append(parts, fmt.Sprintf("%s %s", v.Column, v.Direction))
+		if v.Column == "" {
+			continue
+		}
+
+		parts = append(parts, v.build(v.Column))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// buildOuter returns the orders that only exist on the wrapping query, like the aliases of aggregates.
+func (ob *OrderBuilder) buildOuter() string {
+	parts := make([]string, 0)
+	for _, v := range ob.orders {
+		if v.Column != "" {
+			continue
+		}
+
+		parts = append(parts, v.build(v.parent))
 	}
 
 	return strings.Join(parts, ", ")
 }
 
+func (o Order) build(column string) string {
+	if o.Nulls != "" {
+		return fmt.Sprintf("%s %s %s", o.normalize.column(column), o.Direction, o.Nulls)
+	}
+
+	return fmt.Sprintf("%s %s", o.normalize.column(column), o.Direction)
+}
+
+// matches reports whether the order is on the column, orders on the wrapping query only are matched on their parent.
+func (o Order) matches(column string) bool {
+	if o.Column == "" {
+		return o.parent == column
+	}
+
+	return o.Column == column
+}
+
 func ParseURLOrderQueryToOrderBuilder(query string) (*OrderBuilder, error) {
 	ob := NewOrderBuilder()
 	orders := strings.Split(query, ",")
 
 	for _, order := range orders {
 		parts := strings.Split(order, "|")
-		if len(parts) != 2 {
+		if len(parts) != 2 && len(parts) != 3 {
 			return nil, fmt.Errorf("invalid order format: %s", order)
 		}
 
@@ -88,7 +173,15 @@ func ParseURLOrderQueryToOrderBuilder(query string) (*OrderBuilder, error) {
 			return nil, fmt.Errorf("invalid order direction: %s", direction)
 		}
 
-		ob.OrderBy(column, direction)
+		var nulls OrderNulls
+		if len(parts) == 3 {
+			var ok bool
+			if nulls, ok = parseOrderNulls(parts[2]); !ok {
+				return nil, fmt.Errorf("invalid order modifier: %s", parts[2])
+			}
+		}
+
+		ob.OrderByNulls(column, direction, nulls)
 	}
 
 	return ob, nil
@@ -103,11 +196,11 @@ func (l *Liqu) parseOrderBy(query string) error {
 
 	for _, order := range orders {
 		parts := strings.Split(order, "|")
-		if len(parts) != 2 {
+		if len(parts) < 2 {
 			return fmt.Errorf("invalid order format: %s", order)
 		}
 
-		err := l.processOrderBy(parts[0], parts[1])
+		err := l.processOrderBy(parts[0], parts[1], parts[2:]...)
 		if err != nil {
 			return err
 		}
@@ -116,11 +209,15 @@ func (l *Liqu) parseOrderBy(query string) error {
 	return nil
 }
 
-func (l *Liqu) processOrderBy(col, dir string) error {
+// processOrderBy orders on the field, modifiers follow the direction, like `Date|DESC|NULLSLAST` to place
+// the NULL values last or `Location|ASC|near:52.37--4.89` to order on the distance from a point.
+func (l *Liqu) processOrderBy(col, dir string, modifiers ...string) error {
 	var (
 		model  string
 		field  string
 		column string
+		near   *geoPoint
+		nulls  OrderNulls
 	)
 
 	if strings.Contains(col, ".") {
@@ -131,33 +228,288 @@ func (l *Liqu) processOrderBy(col, dir string) error {
 		model = l.tree.as
 		field = col
 	}
+	field = l.resolveField(model, field)
+
+	direction := OrderDirection(dir)
+	if direction != Asc && direction != Desc {
+		return fmt.Errorf("invalid order direction: %s", direction)
+	}
+
+	for _, modifier := range modifiers {
+		if n, ok := parseOrderNulls(modifier); ok {
+			nulls = n
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(modifier, "near:"):
+			p, err := parseGeoPoint(strings.TrimPrefix(modifier, "near:"))
+			if err != nil {
+				return fmt.Errorf("invalid order field %s: %w", col, err)
+			}
+
+			near = &p
+		default:
+			return fmt.Errorf("invalid order modifier: %s", modifier)
+		}
+	}
+
+	// the relation aggregates are ordered on through the column of their lateral join
+	if agg, ok := l.relationAggregate(model, field); ok {
+		l.orderOnRoot(Order{Direction: direction, Nulls: nulls}, fmt.Sprintf(`"%s"."%s"`, agg.field, agg.field))
+
+		return nil
+	}
+
+	// aggregates can only be ordered on after grouping, which happens in the wrapping query
+	if branch := l.registry[model].branch; branch != nil {
+		if agg, ok := branch.aggregate(field); ok {
+			// the aggregate of a relation is computed per row of the root
+			if branch != l.tree {
+				ra, err := l.childAggregate(branch, agg)
+				if err != nil {
+					return fmt.Errorf("invalid order field %s: %w", col, err)
+				}
+
+				l.orderOnRoot(Order{Direction: direction, Nulls: nulls}, fmt.Sprintf("(%s)", l.relationAggregateQuery(ra, !l.tree.anonymous)))
+
+				return nil
+			}
+
+			alias := fmt.Sprintf(`"%s"`, agg.Alias)
+			branch.order.Unset(alias)
+			branch.order.order(Order{
+				Direction: direction,
+				Nulls:     nulls,
+				parent:    alias,
+			})
+
+			return nil
+		}
+
+		// the groups of the aggregates are ordered on in the wrapping query as well
+		if branch == l.tree && len(branch.aggregateFields) > 0 && slices.Contains(branch.aggregateGroups, field) {
+			alias := fmt.Sprintf(`"%s"`, l.aggregateGroupKey(branch, field))
+			if branch.anonymous {
+				alias = l.fieldColumn(model, field)
+			}
+
+			branch.order.Unset(alias)
+			branch.order.order(Order{
+				Direction: direction,
+				Nulls:     nulls,
+				parent:    alias,
+			})
+
+			return nil
+		}
+
+		// the rows of a report are its groups, anything else is gone after grouping
+		if branch.report {
+			return fmt.Errorf("invalid order field %s, reports can only be ordered on their columns", col)
+		}
+	}
+
+	if _, ok := l.registry[model].fieldDatabase[field]; !ok {
+		if expression, ok := sourceExpression(l.registry[model].branch.source, field); ok {
+			return l.processOrderByExpression(model, expression, direction, nulls)
+		}
 
-	var ok bool
-	if column, ok = l.registry[model].fieldDatabase[field]; !ok {
 		return fmt.Errorf("invalid order field %s", col)
 	}
 
-	column = fmt.Sprintf(`"%s"."%s"`, l.registry[model].tableName, column)
+	subQ, isSubQuery := l.registry[model].branch.subQuery[field]
+	if isSubQuery {
+		column = l.subQueryExpression(l.registry[model].branch, subQ)
+	} else {
+		column = l.fieldColumn(model, field)
+	}
+
+	// the rows of the root are ordered on single valued relations through the column exposed by their lateral join
+	if branch := l.registry[model].branch; l.isSingleRelation(branch) {
+		if isSubQuery {
+			return fmt.Errorf("invalid order field %s, sub queries of relations can not be ordered on", col)
+		}
+
+		branch.referencedFields[field] = true
+
+		outer := fmt.Sprintf(`"%s"."%s"`, branch.as, l.registry[model].fieldDatabase[field])
+		if near != nil {
+			outer = l.distance(outer, *near)
+		}
+
+		l.orderOnRoot(Order{
+			Direction: direction,
+			Nulls:     nulls,
+			normalize: l.registry[model].fieldNormalize[field],
+		}, outer)
+
+		return nil
+	}
+
+	// the wrapping query orders on the alias, so the field has to be selected
+	l.registry[model].branch.orderedFields = appendUnique(l.registry[model].branch.orderedFields, field)
+	l.registry[model].branch.selectedFields = appendUnique(l.registry[model].branch.selectedFields, field)
+
+	if near != nil {
+		if isSubQuery {
+			return fmt.Errorf("invalid order field %s, sub queries can not be ordered by distance", col)
+		}
+
+		distance := l.distance(column, *near)
+		l.registry[model].branch.order.Unset(distance)
+		l.registry[model].branch.order.order(Order{
+			Column:    distance,
+			Direction: direction,
+			Nulls:     nulls,
+			parent:    l.distance(fmt.Sprintf(`"%s"`, field), *near),
+		})
+		l.registry[model].branch.groupBy.GroupBy(column)
+
+		return nil
+	}
 
 	if l.registry[model].branch.order.HasOrderBy(column) {
 		l.registry[model].branch.order.Unset(column)
 	}
 
-	direction := OrderDirection(dir)
-	if direction != Asc && direction != Desc {
-		return fmt.Errorf("invalid order direction: %s", direction)
+	l.registry[model].branch.order.order(Order{
+		Column:    column,
+		Direction: direction,
+		Nulls:     nulls,
+		parent:    fmt.Sprintf(`"%s"`, field),
+		normalize: l.registry[model].fieldNormalize[field],
+	})
+
+	if !isSubQuery {
+		l.groupByField(l.registry[model].branch, field)
+	}
+
+	return nil
+}
+
+// isSingleRelation reports whether the branch is a single valued relation of the root
+func (l *Liqu) isSingleRelation(branch *branch) bool {
+	return branch.parent == l.tree && !branch.slice && !branch.isCTE && !branch.anonymous && len(branch.relations) > 0
+}
+
+// orderOnRoot orders the rows of the root on a column of the wrapping query, like the columns exposed by lateral joins.
+func (l *Liqu) orderOnRoot(order Order, outer string) {
+	// without a wrapping query the lateral joins are on the same level as the columns
+	if l.tree.anonymous {
+		order.Column = outer
+	} else {
+		order.parent = outer
+	}
+
+	l.tree.order.Unset(outer)
+	l.tree.order.order(order)
+}
+
+// processOrderByExpression orders on a whitelisted expression of the source
+func (l *Liqu) processOrderByExpression(model, expression string, direction OrderDirection, nulls OrderNulls) error {
+	var (
+		branch = l.registry[model].branch
+		fields []string
+	)
+
+	for _, match := range expressionFieldRegex.FindAllStringSubmatch(expression, -1) {
+		if _, ok := l.registry[model].fieldDatabase[match[1]]; !ok {
+			return fmt.Errorf("invalid order expression %s, unknown field %s", expression, match[1])
+		}
+
+		if _, ok := branch.subQuery[match[1]]; ok {
+			return fmt.Errorf("invalid order expression %s, sub query fields can not be used", expression)
+		}
+
+		fields = appendUnique(fields, match[1])
+	}
+
+	expand := func(column func(field string) string) string {
+		return expressionFieldRegex.ReplaceAllStringFunc(expression, func(s string) string {
+			return column(s[1 : len(s)-1])
+		})
+	}
+
+	if l.isSingleRelation(branch) {
+		for _, field := range fields {
+			branch.referencedFields[field] = true
+		}
+
+		l.orderOnRoot(Order{Direction: direction, Nulls: nulls}, expand(func(field string) string {
+			return fmt.Sprintf(`"%s"."%s"`, branch.as, l.registry[model].fieldDatabase[field])
+		}))
+
+		return nil
 	}
 
-	l.registry[model].branch.order.OrderBy(column, direction)
-	l.registry[model].branch.groupBy.GroupBy(column)
+	inner := expand(func(field string) string {
+		return l.fieldColumn(model, field)
+	})
+
+	branch.order.Unset(inner)
+	branch.order.order(Order{
+		Column:    inner,
+		Direction: direction,
+		Nulls:     nulls,
+		parent: expand(func(field string) string {
+			return fmt.Sprintf(`"%s"`, field)
+		}),
+	})
+
+	for _, field := range fields {
+		branch.orderedFields = appendUnique(branch.orderedFields, field)
+		branch.selectedFields = appendUnique(branch.selectedFields, field)
+		branch.groupBy.GroupBy(l.fieldColumn(model, field))
+	}
 
 	return nil
 }
 
+// sourceExpression returns the whitelisted expression of the source by its name
+func sourceExpression(source Source, name string) (string, bool) {
+	e, ok := source.(Expressions)
+	if !ok {
+		return "", false
+	}
+
+	expression, ok := e.Expressions()[name]
+
+	return expression, ok
+}
+
+// parentOrder translates the order of the branch to the columns it exposes to the wrapping query.
+// when the wrapping query is grouped, the columns are added to the group by as well.
+func (l *Liqu) parentOrder(branch *branch, groupBy *GroupByBuilder) *OrderBuilder {
+	no := NewOrderBuilder()
+
+	for _, v := range branch.order.orders {
+		if v.parent == "" {
+			continue
+		}
+
+		if len(groupBy.groups) > 0 {
+			groupBy.GroupBy(v.parent)
+		}
+
+		no.order(Order{
+			Column:    v.parent,
+			Direction: v.Direction,
+			Nulls:     v.Nulls,
+			normalize: v.normalize,
+		})
+	}
+
+	return no
+}
+
+var expressionFieldRegex = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
 type ExtractedOrder struct {
 	Table     string
 	Column    string
 	Direction string
+	Nulls     string
 }
 
 // ExtractOrders takes a SQL order by clause and extracts columns and directions.
@@ -165,7 +517,7 @@ func ExtractOrders(orderClause string) ([]ExtractedOrder, error) {
 	var orders []ExtractedOrder
 
 	// Regular expression to match the table, column and direction
-	re := regexp.MustCompile(`(?i)"([^"]+)"\."([^"]+)"\s*(ASC|DESC)?`)
+	re := regexp.MustCompile(`(?i)"([^"]+)"\."([^"]+)"\s*(ASC|DESC)?(?:\s+NULLS\s+(FIRST|LAST))?`)
 
 	matches := re.FindAllStringSubmatch(orderClause, -1)
 
@@ -184,6 +536,10 @@ func ExtractOrders(orderClause string) ([]ExtractedOrder, error) {
 			order.Direction = "ASC"
 		}
 
+		if match[4] != "" {
+			order.Nulls = "NULLS " + strings.ToUpper(match[4])
+		}
+
 		orders = append(orders, order)
 	}
 
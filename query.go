@@ -7,12 +7,15 @@ import (
 )
 
 var (
-	rootQuery           = `SELECT :totalRows: :select: FROM ( :from: :where: :groupBy: :orderBy:) :as: :join: :whereNulls: :groupByCTE: :orderByParent: :limit:`
-	anonRootQuery       = `SELECT :totalRows: :select: FROM :from: :join: :whereNulls: :where: :groupBy: :orderBy: :groupByCTE: :limit: `
-	baseQuery           = `SELECT :select: FROM ":from:" :as: :join: :where: :groupBy: :orderBy: :limit:`
+	rootQuery           = `SELECT :totalRows: :select: FROM ( :from: :where: :groupBy: :orderBy:) :as: :join: :whereNulls: :groupByCTE: :having: :orderByParent: :limit:`
+	anonRootQuery       = `SELECT :totalRows: :select: FROM :from: :join: :whereNulls: :where: :groupBy: :having: :orderBy: :groupByCTE: :limit: `
+	baseQuery           = `SELECT :distinct: :select: FROM ":from:" :as: :join: :where: :groupBy: :orderBy: :limit:`
+	baseLimitedQuery    = `SELECT :select: FROM ( :from: ) :as: :join: :groupBy:`
+	limitedQuery        = `SELECT :distinct: * FROM ":from:" :where: :orderBy: :limit:`
 	lateralQuery        = `:direction: JOIN LATERAL ( :query: ) :as: ON true`
 	singleQuery         = `:cteBranchedQueries: SELECT coalesce(to_jsonb(q),'{}') FROM ( :query: ) q`
 	sliceQuery          = `:cteBranchedQueries: SELECT coalesce(jsonb_agg(q),'[]') FROM ( :query: ) q`
+	rowQuery            = `:cteBranchedQueries: SELECT to_jsonb(q) FROM ( :query: ) q`
 	branchSingleQuery   = `to_jsonb( :select: ) :as:`
 	branchSliceQuery    = `COALESCE(jsonb_agg( :select: :orderBy: ) FILTER ( WHERE :select: IS NOT NULL ),'[]' )  :as:`
 	branchSliceCTEQuery = `COALESCE(:select:, '[]') :as:`
@@ -38,6 +41,12 @@ func newSingleQuery() *query {
 	}
 }
 
+func newRowQuery() *query {
+	return &query{
+		q: rowQuery,
+	}
+}
+
 func newRootQuery() *query {
 	return &query{
 		q: rootQuery,
@@ -56,6 +65,18 @@ func newBaseQuery() *query {
 	}
 }
 
+func newBaseLimitedQuery() *query {
+	return &query{
+		q: baseLimitedQuery,
+	}
+}
+
+func newLimitedQuery() *query {
+	return &query{
+		q: limitedQuery,
+	}
+}
+
 func newLateralQuery() *query {
 	return &query{
 		q: lateralQuery,
@@ -98,6 +119,12 @@ func (q *query) setSelect(value string) *query {
 	return q
 }
 
+func (q *query) setDistinct(value string) *query {
+	q.q = strings.Replace(q.q, ":distinct:", value, 1)
+
+	return q
+}
+
 func (q *query) setCTE(value string) *query {
 	cte := ""
 	if value != "" {
@@ -149,6 +176,18 @@ func (q *query) setWhereNulls(value string) *query {
 	return q
 }
 
+func (q *query) setHaving(value string) *query {
+	var having string
+
+	if value != "" {
+		having = fmt.Sprintf("HAVING %s ", value)
+	}
+
+	q.q = strings.Replace(q.q, ":having:", having, 1)
+
+	return q
+}
+
 func (q *query) setFrom(value string) *query {
 	q.q = strings.Replace(q.q, ":from:", value, 1)
 
@@ -0,0 +1,181 @@
+package liqu
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type (
+	// relationAggregate is a field of the root computed over the rows of a relation, declared as
+	// `liqu:"count:Articles"` or `liqu:"max:Articles.Date"`. the relation itself does not have to be loaded,
+	// which it is not when it is tagged with `liqu:"-"`.
+	relationAggregate struct {
+		field    string
+		key      string
+		fn       Aggregator
+		relation string
+		target   string
+	}
+)
+
+var relationAggregators = []Aggregator{AggCount, AggSum, AggAvg, AggMin, AggMax}
+
+// parseRelationAggregate returns the relation aggregate declared in the liqu tag of the field, if any
+func parseRelationAggregate(structField reflect.StructField) (relationAggregate, bool) {
+	options := liquTagOptions(structField.Tag.Get("liqu"))
+
+	for _, fn := range relationAggregators {
+		value, ok := options[strings.ToLower(string(fn))]
+		if !ok || value == "" {
+			continue
+		}
+
+		relation, target, _ := strings.Cut(value, ".")
+
+		key := jsonKey(structField.Tag)
+		if key == "" || key == "-" {
+			key = structField.Name
+		}
+
+		return relationAggregate{
+			field:    structField.Name,
+			key:      key,
+			fn:       fn,
+			relation: relation,
+			target:   target,
+		}, true
+	}
+
+	return relationAggregate{}, false
+}
+
+// processRelationAggregates validates the relation aggregates of the root against the relations that were scanned
+func (l *Liqu) processRelationAggregates() error {
+	for _, agg := range l.tree.relationAggregates {
+		reg, ok := l.registry[agg.relation]
+		if !ok || reg.branch == nil || reg.branch.parent != l.tree || reg.branch.isCTE || len(reg.branch.relations) == 0 {
+			return fmt.Errorf("invalid relation aggregate %s, %s is not a relation of the root", agg.field, agg.relation)
+		}
+
+		for _, v := range reg.branch.relations {
+			if !v.parent {
+				return fmt.Errorf("invalid relation aggregate %s, %s requires a relation to the root", agg.field, agg.relation)
+			}
+		}
+
+		if agg.target == "" && agg.fn != AggCount {
+			return fmt.Errorf("invalid relation aggregate %s, %s requires a field", agg.field, agg.fn)
+		}
+
+		if agg.target != "" {
+			if _, ok = reg.fieldDatabase[agg.target]; !ok {
+				return fmt.Errorf("invalid relation aggregate %s, unknown field %s.%s", agg.field, agg.relation, agg.target)
+			}
+		}
+	}
+
+	return nil
+}
+
+// relationAggregate returns the relation aggregate of the root with the name of the field
+func (l *Liqu) relationAggregate(model, field string) (relationAggregate, bool) {
+	if model != l.tree.as {
+		return relationAggregate{}, false
+	}
+
+	for _, v := range l.tree.relationAggregates {
+		if v.field == field || v.key == field {
+			return v, true
+		}
+	}
+
+	return relationAggregate{}, false
+}
+
+// childAggregate returns the aggregate of a relation of the root as a relation aggregate, it is computed over all
+// rows of the relation related to the root regardless of the conditions on the relation.
+func (l *Liqu) childAggregate(branch *branch, agg aggregateField) (relationAggregate, error) {
+	if branch.parent != l.tree || branch.isCTE || len(branch.relations) == 0 {
+		return relationAggregate{}, fmt.Errorf("aggregates can only be used on the root and its relations")
+	}
+
+	for _, v := range branch.relations {
+		if !v.parent {
+			return relationAggregate{}, fmt.Errorf("aggregates of %s require a relation to the root", branch.as)
+		}
+	}
+
+	return relationAggregate{
+		field:    agg.Alias,
+		key:      agg.Alias,
+		fn:       agg.Func,
+		relation: branch.as,
+		target:   agg.Field,
+	}, nil
+}
+
+// relationAggregateQuery returns the query computing the aggregate, correlated with the root through the relation.
+// wrapped reports whether the root is referenced through the columns of the wrapping query.
+func (l *Liqu) relationAggregateQuery(agg relationAggregate, wrapped bool) string {
+	var (
+		reg   = l.registry[agg.relation]
+		where = NewConditionBuilder()
+	)
+
+	for _, v := range reg.branch.relations {
+		external := fmt.Sprintf(`"%s"."%s"`, l.tree.registry.tableName, l.tree.registry.fieldDatabase[v.externalField])
+		if wrapped {
+			external = fmt.Sprintf(`"%s"."%s"`, l.tree.as, v.externalField)
+			l.tree.referencedFields[v.externalField] = true
+		}
+
+		where.AndRaw(fmt.Sprintf(`"%s"."%s" %s %s`, reg.tableName, reg.fieldDatabase[v.localField], v.operator, external))
+	}
+
+	column := "*"
+	if agg.target != "" {
+		column = l.fieldColumn(agg.relation, agg.target)
+	}
+
+	return newBaseQuery().
+		setSelect(fmt.Sprintf(`%s(%s) AS "%s"`, agg.fn, column, agg.field)).
+		setFrom(reg.tableName).
+		setWhere(where.Build()).
+		Scrub()
+}
+
+// processRelationAggregateWhere filters the root on the aggregate, the aggregate is computed inside the condition
+// so the rows are filtered before they are paginated.
+func (l *Liqu) processRelationAggregateWhere(outerOperator Operator, agg relationAggregate, op Operator, val interface{}) error {
+	expression := fmt.Sprintf("(%s)", l.relationAggregateQuery(agg, false))
+
+	// the value is compared as the field it is computed from
+	model, field := l.tree.as, agg.field
+	if agg.target != "" {
+		model, field = agg.relation, agg.target
+	}
+
+	err := l.condition(l.tree.where, outerOperator, model, field, expression, op, val)
+	if err != nil {
+		return fmt.Errorf("invalid search field %s: %w", agg.field, err)
+	}
+
+	return nil
+}
+
+// traverseRelationAggregates joins the relation aggregates on the root and returns their columns
+func (l *Liqu) traverseRelationAggregates() []string {
+	columns := make([]string, 0)
+
+	for _, agg := range l.tree.relationAggregates {
+		l.tree.joinBranched = append(
+			l.tree.joinBranched,
+			newLateralQuery().setQuery(l.relationAggregateQuery(agg, !l.tree.anonymous)).setDirection(string(leftJoin)).setAs(agg.field).Scrub(),
+		)
+
+		columns = append(columns, fmt.Sprintf(`"%s"."%s"`, agg.field, agg.field))
+	}
+
+	return columns
+}
@@ -0,0 +1,68 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+)
+
+type (
+	Category struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+
+	CategoryList struct {
+		Category Category
+
+		Articles     []Article `related:"Articles.CategoryID=Category.ID" liqu:"-"`
+		ArticleCount int       `liqu:"count:Articles" json:"article_count"`
+		LastTitle    string    `liqu:"max:Articles.Title"`
+	}
+)
+
+func (m *Category) Table() string {
+	return "category"
+}
+
+func (m *Category) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
+func TestWithRelationAggregate(t *testing.T) {
+	filters := &Filters{
+		Where:   "ArticleCount|>|5",
+		OrderBy: "ArticleCount|DESC",
+	}
+
+	li := New(context.TODO(), filters).
+		WithoutTieBreaker()
+
+	err := li.FromSource(make([]CategoryList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Category" ) AS "Category", "ArticleCount"."ArticleCount" AS "article_count", "LastTitle"."LastTitle" AS "LastTitle" FROM ( SELECT "category"."id" AS "ID" FROM "category" WHERE (SELECT COUNT(*) AS "ArticleCount" FROM "article" WHERE "article"."category_id" = "category"."id") > $1 GROUP BY "category"."id" ) AS "Category" LEFT JOIN LATERAL ( SELECT COUNT(*) AS "ArticleCount" FROM "article" WHERE "article"."category_id" = "Category"."ID" ) AS "ArticleCount" ON true LEFT JOIN LATERAL ( SELECT MAX("article"."title") AS "LastTitle" FROM "article" WHERE "article"."category_id" = "Category"."ID" ) AS "LastTitle" ON true ORDER BY "ArticleCount"."ArticleCount" DESC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 1 {
+		t.Errorf("expected 1 params, got %d", len(sqlParams))
+	}
+
+	li = New(context.TODO(), &Filters{Select: "Articles"})
+	if err = li.FromSource(make([]CategoryList, 0)); err != nil {
+		t.Error(err)
+	}
+
+	sqlQuery, _ = li.SQL()
+
+	expected = `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Category" ) AS "Category", "ArticleCount"."ArticleCount" AS "article_count", "LastTitle"."LastTitle" AS "LastTitle", "Articles"."Articles" AS "Articles" FROM ( SELECT "category"."id" AS "ID" FROM "category" GROUP BY "category"."id" ORDER BY "category"."id" ASC) AS "Category" INNER JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'id', "article"."id", 'title', "article"."title", 'Body', "article"."body", 'category_id', "article"."category_id" ) ) FILTER ( WHERE jsonb_build_object( 'id', "article"."id", 'title', "article"."title", 'Body', "article"."body", 'category_id', "article"."category_id" ) IS NOT NULL ),'[]' ) AS "Articles" FROM "article" WHERE category_id = "Category"."ID" ) AS "Articles" ON true LEFT JOIN LATERAL ( SELECT COUNT(*) AS "ArticleCount" FROM "article" WHERE "article"."category_id" = "Category"."ID" ) AS "ArticleCount" ON true LEFT JOIN LATERAL ( SELECT MAX("article"."title") AS "LastTitle" FROM "article" WHERE "article"."category_id" = "Category"."ID" ) AS "LastTitle" ON true WHERE "Articles" IS NOT NULL ORDER BY "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+}
@@ -0,0 +1,130 @@
+package liqu
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type (
+	// reportColumn is a column of a report, either a group declared as `liqu:"group"` or an aggregate declared
+	// as `liqu:"count"` or `liqu:"sum:Volume"`. the field of the model defaults to the name of the column.
+	reportColumn struct {
+		field  string
+		key    string
+		fn     Aggregator
+		target string
+	}
+)
+
+// parseReportColumn returns the report column declared in the liqu tag of the field, if any
+func parseReportColumn(structField reflect.StructField) (reportColumn, bool) {
+	options := liquTagOptions(structField.Tag.Get("liqu"))
+
+	key := jsonKey(structField.Tag)
+	if key == "" || key == "-" {
+		key = structField.Name
+	}
+
+	column := reportColumn{
+		field: structField.Name,
+		key:   key,
+	}
+
+	if target, ok := options["group"]; ok {
+		column.target = target
+		if column.target == "" {
+			column.target = structField.Name
+		}
+
+		return column, true
+	}
+
+	for _, fn := range relationAggregators {
+		target, ok := options[strings.ToLower(string(fn))]
+		if !ok {
+			continue
+		}
+
+		column.fn = fn
+		column.target = target
+
+		return column, true
+	}
+
+	return reportColumn{}, false
+}
+
+// group reports whether the column is one of the groups of the report
+func (c reportColumn) group() bool {
+	return c.fn == ""
+}
+
+// processReport registers the columns of a report as the aggregates and groups of the root, the groups are
+// registered last as the root is only grouped when it has aggregates.
+func (l *Liqu) processReport() error {
+	if !l.tree.report {
+		return nil
+	}
+
+	if len(l.tree.branches) > 0 || len(l.tree.relationAggregates) > 0 {
+		return fmt.Errorf("invalid report %s, relations are not supported in a report", l.tree.as)
+	}
+
+	var groups bool
+	for _, v := range l.tree.reportColumns {
+		if v.target != "" {
+			if _, ok := l.registry[l.tree.as].fieldDatabase[v.target]; !ok {
+				return fmt.Errorf("invalid report column %s, unknown field %s.%s", v.field, l.tree.as, v.target)
+			}
+		}
+
+		if v.group() {
+			groups = true
+			continue
+		}
+
+		if v.target == "" && v.fn != AggCount {
+			return fmt.Errorf("invalid report column %s, %s requires a field", v.field, v.fn)
+		}
+
+		if v.target != "" {
+			l.processSelect(l.tree.as, v.target)
+		}
+
+		l.processSelectAggregate(l.tree.as, v.target, v.key, v.fn)
+	}
+
+	if len(l.tree.aggregateFields) == 0 {
+		return fmt.Errorf("invalid report %s, a report requires at least one aggregate", l.tree.as)
+	}
+
+	if !groups {
+		return nil
+	}
+
+	for _, v := range l.tree.reportColumns {
+		if !v.group() {
+			continue
+		}
+
+		err := l.processGroupBy(v.target)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// aggregateGroupKey returns the key a group of the aggregates of the branch is returned under, the column of a
+// report is returned under its own name.
+func (l *Liqu) aggregateGroupKey(branch *branch, field string) string {
+	for _, v := range branch.reportColumns {
+		if v.group() && v.target == field {
+			return v.key
+		}
+	}
+
+	return l.fieldKey(branch.as, field)
+}
@@ -0,0 +1,69 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+)
+
+type (
+	ProjectReport struct {
+		Project   Project `liqu:"report"`
+		Company   int     `json:"company" liqu:"group:CompanyID"`
+		Count     int     `liqu:"count"`
+		SumVolume float64 `liqu:"sum:Volume"`
+		MaxVolume float64 `liqu:"max:Volume"`
+	}
+
+	ProjectTotalsReport struct {
+		Project Project `liqu:"report"`
+		Count   int     `liqu:"count"`
+	}
+)
+
+func TestWithReport(t *testing.T) {
+	filters := &Filters{
+		Where:   "Project.Name|ILIKE|liqu,Count|>|2",
+		OrderBy: "SumVolume|DESC",
+		Page:    2,
+		PerPage: 10,
+	}
+
+	li := New(context.TODO(), filters)
+
+	err := li.FromSource(make([]ProjectReport, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, "Project"."CompanyID" AS "company", COUNT(*) AS "Count", SUM("Project"."Volume") AS "SumVolume", MAX("Project"."Volume") AS "MaxVolume" FROM ( SELECT "project"."id" AS "ID", "project"."volume" AS "Volume", "project"."company_id" AS "CompanyID", "project"."name" AS "Name" FROM "project" WHERE "project"."name" ILIKE $1 GROUP BY "project"."company_id", "project"."id", "project"."volume", "project"."name" ) AS "Project" GROUP BY "Project"."CompanyID" HAVING COUNT(*) > $2 ORDER BY "SumVolume" DESC, "company" ASC LIMIT 10 OFFSET 10 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 2 {
+		t.Errorf("expected 2 params, got %d", len(sqlParams))
+	}
+
+	li = New(context.TODO(), nil)
+
+	err = li.FromSource(ProjectTotalsReport{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ = li.SQL()
+
+	expected = `SELECT coalesce(to_jsonb(q),'{}') FROM ( SELECT COUNT(*) AS "Count" FROM ( SELECT "project"."id" AS "ID" FROM "project" GROUP BY "project"."id" ) AS "Project" LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	li = New(context.TODO(), &Filters{OrderBy: "Project.Name|ASC"})
+	if err = li.FromSource(make([]ProjectReport, 0)); err == nil {
+		t.Error("expected an error when ordering a report on a field that is not one of its columns")
+	}
+}
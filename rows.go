@@ -0,0 +1,104 @@
+package liqu
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"sync/atomic"
+)
+
+type (
+	// CursorExecutor runs the statements of a server side cursor, it is implemented by *sql.Tx.
+	// cursors only live inside a transaction, so the statements have to run on the same one.
+	CursorExecutor interface {
+		Executor
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	}
+)
+
+// CursorFetchSize is the number of rows fetched from the cursor at once by Rows
+var CursorFetchSize = 100
+
+var cursorCounter atomic.Uint64
+
+// WithRows builds a query returning every row of the root as a row of its own, instead of a single json array
+// holding the whole page. it has to be called before FromSource, the rows are read with Rows.
+func (l *Liqu) WithRows() *Liqu {
+	l.rows = true
+
+	return l
+}
+
+// Rows runs the query through a server side cursor and yields the rows of the root one by one, decoded into T,
+// which is the element of the source. only CursorFetchSize rows are held at any time, whatever the page size is.
+func Rows[T any](ctx context.Context, l *Liqu, exec CursorExecutor) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		if !l.rows || l.tree == nil {
+			yield(zero, errors.New("[liqu] rows require WithRows or ForExport to be called before FromSource"))
+			return
+		}
+
+		cursor := fmt.Sprintf("liqu_rows_%d", cursorCounter.Add(1))
+
+		_, err := exec.ExecContext(ctx, fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", cursor, l.sqlQuery), l.sqlParams...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		// the cursor is closed even when the context is done, the transaction may still be used afterwards
+		defer func() {
+			_, _ = exec.ExecContext(context.WithoutCancel(ctx), fmt.Sprintf("CLOSE %s", cursor))
+		}()
+
+		fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", CursorFetchSize, cursor)
+
+		for {
+			fetched, err := fetchRows(ctx, exec, fetch, yield)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			if fetched < CursorFetchSize {
+				return
+			}
+		}
+	}
+}
+
+// fetchRows fetches the next rows from the cursor and yields them, it returns the number of rows fetched
+// or -1 when the caller stopped iterating.
+func fetchRows[T any](ctx context.Context, exec CursorExecutor, fetch string, yield func(T, error) bool) (int, error) {
+	rows, err := exec.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var fetched int
+	for rows.Next() {
+		fetched++
+
+		var row []byte
+		if err = rows.Scan(&row); err != nil {
+			return fetched, err
+		}
+
+		var value T
+		if err = json.Unmarshal(row, &value); err != nil {
+			return fetched, err
+		}
+
+		if !yield(value, nil) {
+			return -1, nil
+		}
+	}
+
+	return fetched, rows.Err()
+}
@@ -0,0 +1,42 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithRows(t *testing.T) {
+	filters := &Filters{
+		Select:  "Author.name,Articles.title",
+		OrderBy: "Author.name|ASC",
+		Page:    2,
+		PerPage: 500,
+	}
+
+	li := New(context.TODO(), filters).WithRows()
+
+	err := li.FromSource(make([]AuthorArticleList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, _ := li.SQL()
+
+	expected := `SELECT to_jsonb(q) FROM ( SELECT to_jsonb( jsonb_build_object( 'id', "Author"."ID", 'name', "Author"."Name" ) ) AS "Author", "Articles"."Articles" AS "articles" FROM ( SELECT "author"."name" AS "Name", "author"."id" AS "ID" FROM "author" GROUP BY "author"."name", "author"."id" ORDER BY "author"."name" ASC, "author"."id" ASC) AS "Author" LEFT JOIN LATERAL ( SELECT COALESCE(jsonb_agg( jsonb_build_object( 'id', "article"."id", 'title', "article"."title" ) ) FILTER ( WHERE jsonb_build_object( 'id', "article"."id", 'title', "article"."title" ) IS NOT NULL ),'[]' ) AS "Articles" FROM "article" WHERE author_id = "Author"."ID" ) AS "Articles" ON true ORDER BY "Name" ASC, "ID" ASC LIMIT 500 OFFSET 500 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	li = New(context.TODO(), filters)
+	if err = li.FromSource(make([]AuthorArticleList, 0)); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, err = range Rows[AuthorArticleList](context.TODO(), li, nil) {
+		if err == nil {
+			t.Error("expected an error on rows without WithRows")
+		}
+	}
+}
@@ -63,6 +63,11 @@ func (l *Liqu) scan(sourceType reflect.Type, parent *branch) error {
 		// get the structTags
 		mainTag := sourceType.Field(0).Tag
 
+		var key string
+		if !anonymous {
+			key = jsonKey(mainTag)
+		}
+
 		where := NewConditionBuilder().setLiqu(l)
 		if mainTag.Get("whereRaw") != "" {
 			where = where.AndRaw(mainTag.Get("whereRaw"))
@@ -75,15 +80,20 @@ func (l *Liqu) scan(sourceType reflect.Type, parent *branch) error {
 			slice:            sourceSlice,
 			anonymous:        anonymous,
 			as:               sourceAs,
+			key:              key,
 			name:             sourceName,
 			source:           source,
 			branches:         make([]*branch, 0),
 			where:            where,
+			outerWhere:       NewConditionBuilder().setLiqu(l),
+			having:           NewConditionBuilder().setLiqu(l),
 			order:            NewOrderBuilder(),
 			groupBy:          NewGroupByBuilder(),
 			selectedFields:   make([]string, 0),
 			aggregateFields:  make([]aggregateField, 0),
 			distinctFields:   make(map[string]bool),
+			distinctOn:       splitFields(mainTag.Get("distinct_on")),
+			report:           hasOption(liquTagOptions(mainTag.Get("liqu")), "report"),
 			referencedFields: make(map[string]bool),
 			subQuery:         make(map[string]*SubQuery),
 		}
@@ -97,11 +107,16 @@ func (l *Liqu) scan(sourceType reflect.Type, parent *branch) error {
 
 		// build up the registry, so we can reference fields easier as we build up the query a bit later on
 		r := &registry{
-			fieldTypes:    structFields.fieldTypes,
-			fieldDatabase: structFields.fieldDatabase,
-			fieldSearch:   make(map[string]interface{}),
-			branch:        parent,
-			tableName:     source.Table(),
+			fieldTypes:      structFields.fieldTypes,
+			fieldDatabase:   structFields.fieldDatabase,
+			fieldNormalize:  structFields.fieldNormalize,
+			fieldExpression: structFields.fieldExpression,
+			fieldWindow:     structFields.fieldWindow,
+			fieldJSON:       structFields.fieldJSON,
+			fieldOrder:      structFields.fieldOrder,
+			fieldSearch:     make(map[string]interface{}),
+			branch:          parent,
+			tableName:       source.Table(),
 		}
 
 		parent.registry = r
@@ -123,15 +138,27 @@ func (l *Liqu) scan(sourceType reflect.Type, parent *branch) error {
 }
 
 type StructFieldInfo struct {
-	fieldTypes    map[string]reflect.Type
-	fieldDatabase map[string]string
-	selectAs      string
+	fieldOrder     []string
+	fieldTypes     map[string]reflect.Type
+	fieldDatabase  map[string]string
+	fieldNormalize map[string]normalize
+	// fieldExpression holds the sql of computed fields, declared as `liqu:"expr:first_name || ' ' || last_name"`
+	fieldExpression map[string]string
+	// fieldWindow marks the computed fields using a window function, declared as `liqu:"window:rank() OVER (ORDER BY {Score} DESC)"`
+	fieldWindow map[string]bool
+	// fieldJSON holds the output keys of the fields from their json tag, fields that are never returned have the key "-"
+	fieldJSON map[string]string
+	selectAs  string
 }
 
 func (l *Liqu) structFields(source interface{}) StructFieldInfo {
 	structFieldInfo := &StructFieldInfo{
-		fieldTypes:    make(map[string]reflect.Type, 0),
-		fieldDatabase: make(map[string]string, 0),
+		fieldTypes:      make(map[string]reflect.Type, 0),
+		fieldDatabase:   make(map[string]string, 0),
+		fieldNormalize:  make(map[string]normalize, 0),
+		fieldExpression: make(map[string]string, 0),
+		fieldWindow:     make(map[string]bool, 0),
+		fieldJSON:       make(map[string]string, 0),
 	}
 
 	sourceElem := reflect.ValueOf(source).Elem()
@@ -148,11 +175,18 @@ func (l *Liqu) structFields(source interface{}) StructFieldInfo {
 		structTag := sourceType.Field(i).Tag
 
 		var (
-			liquTag = structTag.Get("liqu")
-			dbTag   = structTag.Get("db")
+			liquOptions          = liquTagOptions(structTag.Get("liqu"))
+			dbTag                = structTag.Get("db")
+			expression, computed = liquOptions["expr"]
+			window, windowed     = liquOptions["window"]
 		)
 
-		if liquTag == "-" || dbTag == "-" {
+		if windowed {
+			expression, computed = window, true
+		}
+
+		// a computed field can be left out of writes with db:"-", while it can still be read
+		if hasOption(liquOptions, "-") || (dbTag == "-" && !computed) {
 			continue
 		}
 
@@ -169,9 +203,10 @@ func (l *Liqu) structFields(source interface{}) StructFieldInfo {
 		}
 
 		if checkSource.Kind() == reflect.Struct {
-			if (liquTag == "append" || i == 0) && sourceType.Field(i).Anonymous {
+			if (hasOption(liquOptions, "append") || i == 0) && sourceType.Field(i).Anonymous {
 				subStructFieldInfo := l.structFields(reflect.New(sourceType.Field(i).Type).Interface())
 
+				structFieldInfo.fieldOrder = append(structFieldInfo.fieldOrder, subStructFieldInfo.fieldOrder...)
 				for k, v := range subStructFieldInfo.fieldTypes {
 					structFieldInfo.fieldTypes[k] = v
 				}
@@ -180,6 +215,22 @@ func (l *Liqu) structFields(source interface{}) StructFieldInfo {
 					structFieldInfo.fieldDatabase[k] = v
 				}
 
+				for k, v := range subStructFieldInfo.fieldNormalize {
+					structFieldInfo.fieldNormalize[k] = v
+				}
+
+				for k, v := range subStructFieldInfo.fieldExpression {
+					structFieldInfo.fieldExpression[k] = v
+				}
+
+				for k, v := range subStructFieldInfo.fieldWindow {
+					structFieldInfo.fieldWindow[k] = v
+				}
+
+				for k, v := range subStructFieldInfo.fieldJSON {
+					structFieldInfo.fieldJSON[k] = v
+				}
+
 				structFieldInfo.selectAs = sourceType.Field(i).Name
 
 				hasSubField = true
@@ -190,12 +241,29 @@ func (l *Liqu) structFields(source interface{}) StructFieldInfo {
 			continue
 		}
 
-		if dbTag == "" {
+		if dbTag == "" || dbTag == "-" {
 			dbTag = toSnakeCase(sourceType.Field(i).Name)
 		}
 
+		if computed {
+			structFieldInfo.fieldExpression[sourceType.Field(i).Name] = expression
+		}
+
+		if windowed {
+			structFieldInfo.fieldWindow[sourceType.Field(i).Name] = true
+		}
+
+		if key := jsonKey(structTag); key != "" {
+			structFieldInfo.fieldJSON[sourceType.Field(i).Name] = key
+		}
+
+		structFieldInfo.fieldOrder = append(structFieldInfo.fieldOrder, sourceType.Field(i).Name)
 		structFieldInfo.fieldTypes[sourceType.Field(i).Name] = sourceType.Field(i).Type
 		structFieldInfo.fieldDatabase[sourceType.Field(i).Name] = dbTag
+
+		if n := normalizeFromTag(liquOptions); !n.isZero() {
+			structFieldInfo.fieldNormalize[sourceType.Field(i).Name] = n
+		}
 	}
 
 	return *structFieldInfo
@@ -213,6 +281,25 @@ func (l *Liqu) processField(structField reflect.StructField, parent *branch) err
 		return l.scanChild(structField, fieldIsSource, parent)
 	}
 
+	// the fields of a report are its columns, computed over the rows of the root
+	if parent == l.tree && parent.report {
+		if column, ok := parseReportColumn(structField); ok {
+			parent.reportColumns = append(parent.reportColumns, column)
+		}
+
+		return nil
+	}
+
+	if agg, ok := parseRelationAggregate(structField); ok {
+		if parent != l.tree {
+			return fmt.Errorf("[liqu] relation aggregate %s is only supported on the root", structField.Name)
+		}
+
+		parent.relationAggregates = append(parent.relationAggregates, agg)
+
+		return nil
+	}
+
 	if fieldType.Kind() == reflect.Struct {
 		for index := 0; index < fieldType.NumField(); index++ {
 			err := l.processField(fieldType.Field(index), parent)
@@ -247,12 +334,14 @@ func (l *Liqu) scanChild(structField reflect.StructField, source Source, parent
 		joinTag     = strings.ToUpper(structField.Tag.Get("join"))
 		relatedTag  = structField.Tag.Get("related")
 		distinctTag = structField.Tag.Get("distinct")
+		onTag       = structField.Tag.Get("distinct_on")
 		limitTag    = structField.Tag.Get("limit")
 		offsetTag   = structField.Tag.Get("offset")
 		selectTag   = structField.Tag.Get("select")
 		orderByTag  = structField.Tag.Get("order_by")
 		groupByTag  = structField.Tag.Get("group_by")
-		liquTag     = structField.Tag.Get("liqu")
+		childrenTag = structField.Tag.Get("children")
+		liquOptions = liquTagOptions(structField.Tag.Get("liqu"))
 	)
 
 	var (
@@ -272,16 +361,23 @@ func (l *Liqu) scanChild(structField reflect.StructField, source Source, parent
 		}
 	}
 
-	var isCTE bool
-	if liquTag != "" {
-		switch liquTag {
-		case "cte":
-			isCTE = true
-		}
+	if !ChildrenMode(childrenTag).valid() {
+		return fmt.Errorf("[liqu] unknown children mode %s on %s", childrenTag, selectFieldAs)
 	}
 
+	isCTE := hasOption(liquOptions, "cte")
+
 	structFields := l.structFields(source)
 	primaryKeys := l.primaryKeys(structFields.fieldDatabase, source)
+	if len(structFields.fieldWindow) > 0 {
+		// name the first window field as declared, the map has no order
+		for _, field := range structFields.fieldOrder {
+			if structFields.fieldWindow[field] {
+				return fmt.Errorf("[liqu] window field %s.%s is only supported on the root", selectFieldAs, field)
+			}
+		}
+	}
+
 	if joinTag == "" {
 		joinTag = "INNER"
 	}
@@ -313,8 +409,10 @@ func (l *Liqu) scanChild(structField reflect.StructField, source Source, parent
 		root:             parent,
 		slice:            selectFieldSlice,
 		as:               selectFieldAs,
+		key:              jsonKey(structField.Tag),
 		name:             selectFieldName,
 		where:            NewConditionBuilder().setLiqu(l),
+		having:           NewConditionBuilder().setLiqu(l),
 		order:            NewOrderBuilder(),
 		groupBy:          NewGroupByBuilder(),
 		limit:            limit,
@@ -324,7 +422,14 @@ func (l *Liqu) scanChild(structField reflect.StructField, source Source, parent
 		subQuery:         make(map[string]*SubQuery),
 		selectedFields:   selectedFields,
 		distinctFields:   distinctFields,
+		distinctOn:       splitFields(onTag),
 		joinDirection:    joinTag,
+		children:         ChildrenMode(childrenTag),
+	}
+
+	// a relation that is never returned is not loaded at all, though it can still be aggregated over
+	if currentBranch.key == "-" || hasOption(liquOptions, "-") {
+		currentBranch.excluded = true
 	}
 
 	for _, v := range primaryKeys {
@@ -332,11 +437,16 @@ func (l *Liqu) scanChild(structField reflect.StructField, source Source, parent
 	}
 
 	reg := &registry{
-		fieldTypes:    structFields.fieldTypes,
-		fieldDatabase: structFields.fieldDatabase,
-		branch:        currentBranch,
-		tableName:     source.Table(),
-		fieldSearch:   make(map[string]interface{}),
+		fieldTypes:      structFields.fieldTypes,
+		fieldDatabase:   structFields.fieldDatabase,
+		fieldNormalize:  structFields.fieldNormalize,
+		fieldExpression: structFields.fieldExpression,
+		fieldWindow:     structFields.fieldWindow,
+		fieldJSON:       structFields.fieldJSON,
+		fieldOrder:      structFields.fieldOrder,
+		branch:          currentBranch,
+		tableName:       source.Table(),
+		fieldSearch:     make(map[string]interface{}),
 	}
 
 	currentBranch.registry = reg
@@ -437,6 +547,69 @@ func (l *Liqu) parseRelated(tag string, branch *branch, parent *branch) error {
 	return nil
 }
 
+// WritableColumns returns the database columns of the source in the order of the struct, leaving out the
+// computed fields, so they can be used to build inserts and updates.
+func WritableColumns(source Source) []string {
+	info := (&Liqu{}).structFields(source)
+
+	columns := make([]string, 0, len(info.fieldOrder))
+	for _, field := range info.fieldOrder {
+		if _, ok := info.fieldExpression[field]; ok {
+			continue
+		}
+
+		if _, ok := reflect.New(info.fieldTypes[field]).Interface().(Source); ok {
+			continue
+		}
+
+		columns = append(columns, info.fieldDatabase[field])
+	}
+
+	return columns
+}
+
+// splitFields splits a comma separated list of fields from a tag
+func splitFields(tag string) []string {
+	fields := make([]string, 0)
+	for _, f := range strings.Split(tag, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
+// jsonKey returns the name in the json tag, which is "-" for fields that are never returned
+func jsonKey(tag reflect.StructTag) string {
+	name, _, _ := strings.Cut(tag.Get("json"), ",")
+
+	return name
+}
+
+// liquTagOptions splits a liqu tag like `unaccent;collate:und-x-icu` into its options
+func liquTagOptions(tag string) map[string]string {
+	options := make(map[string]string)
+
+	for _, option := range strings.Split(tag, ";") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(option, ":")
+		options[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return options
+}
+
+// hasOption reports whether the option is set in the liqu tag, regardless of its value
+func hasOption(options map[string]string, option string) bool {
+	_, ok := options[option]
+	return ok
+}
+
 var (
 	matchFirstCap = regexp.MustCompile("(.)([A-Z][a-z]+)")
 	matchAllCap   = regexp.MustCompile("([a-z0-9])([A-Z])")
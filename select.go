@@ -3,6 +3,7 @@ package liqu
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
 )
 
@@ -16,13 +17,41 @@ const (
 	AggMax   Aggregator = "MAX"
 )
 
+// parseSelect selects the fields to return, the first field selected of a model replaces its default selection.
+// it supports `Model.Field`, `Model.*`, exclusions like `-Model.Field` and relations by name, like `Tags` to return
+// the whole relation or `-Tags` to leave it out all together.
 func (l *Liqu) parseSelect(query string, reset bool) error {
 	if strings.TrimSpace(query) == "" {
 		return nil
 	}
 
-	selects := strings.Split(query, ",")
-	for _, sel := range selects {
+	var (
+		touched    = make(map[string]bool)
+		exclusions = make([]string, 0)
+	)
+
+	for _, sel := range strings.Split(query, ",") {
+		sel = strings.TrimSpace(sel)
+		if sel == "" {
+			continue
+		}
+
+		// exclusions are applied after everything is selected
+		if strings.HasPrefix(sel, "-") {
+			exclusions = append(exclusions, strings.TrimPrefix(sel, "-"))
+			continue
+		}
+
+		if !strings.Contains(sel, ".") {
+			branch, err := l.selectRelation(sel)
+			if err != nil {
+				return err
+			}
+
+			l.selectTree(branch)
+			continue
+		}
+
 		parts := strings.Split(sel, ".")
 		if len(parts) != 2 {
 			return fmt.Errorf("invalid select format: %s", sel)
@@ -30,20 +59,143 @@ func (l *Liqu) parseSelect(query string, reset bool) error {
 
 		var (
 			model = parts[0]
-			field = parts[1]
+			field = l.resolveField(parts[0], parts[1])
 		)
 
-		if reset {
-			l.registry[model].branch.selectedFields = make([]string, 0)
-			for _, v := range l.registry[model].branch.source.PrimaryKeys() {
-				l.registry[model].branch.selectedFields = appendUnique(l.registry[model].branch.selectedFields, v)
-			}
-			reset = false
+		reg, ok := l.registry[model]
+		if !ok {
+			return fmt.Errorf("invalid select field %s", sel)
+		}
+
+		if _, ok = reg.fieldDatabase[field]; !ok && field != "*" {
+			return fmt.Errorf("invalid select field %s", sel)
+		}
+
+		if reset && !touched[model] {
+			l.resetSelect(reg.branch)
+			touched[model] = true
 		}
 
 		l.processSelect(model, field)
 	}
 
+	for _, sel := range exclusions {
+		err := l.processExclusion(sel)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resetSelect drops the selected fields of the branch, except for the ones it can not do without.
+func (l *Liqu) resetSelect(branch *branch) {
+	branch.selectedFields = make([]string, 0)
+	for _, v := range branch.source.PrimaryKeys() {
+		branch.selectedFields = appendUnique(branch.selectedFields, v)
+	}
+
+	// the wrapping query orders, aggregates and groups on the aliases of the root, so these have to stay
+	if branch == l.tree {
+		for _, v := range branch.orderedFields {
+			branch.selectedFields = appendUnique(branch.selectedFields, v)
+		}
+
+		for _, v := range branch.aggregateFields {
+			if v.Field != "" {
+				branch.selectedFields = appendUnique(branch.selectedFields, v.Field)
+			}
+		}
+
+		for _, v := range branch.aggregateGroups {
+			branch.selectedFields = appendUnique(branch.selectedFields, v)
+		}
+	}
+}
+
+// selectRelation returns the branch of the relation, the root can not be selected by name.
+func (l *Liqu) selectRelation(name string) (*branch, error) {
+	reg, ok := l.registry[name]
+	if !ok || reg.branch == nil || reg.branch == l.tree {
+		return nil, fmt.Errorf("invalid select relation %s", name)
+	}
+
+	return reg.branch, nil
+}
+
+// selectTree selects all fields of the branch and of the relations within
+func (l *Liqu) selectTree(branch *branch) {
+	branch.excluded = false
+	l.processSelect(branch.as, "*")
+
+	for _, v := range branch.branches {
+		l.selectTree(v)
+	}
+}
+
+func (l *Liqu) processExclusion(sel string) error {
+	if !strings.Contains(sel, ".") {
+		branch, err := l.selectRelation(sel)
+		if err != nil {
+			return err
+		}
+
+		if branch.isCTE {
+			return fmt.Errorf("invalid select relation %s, cte relations can not be excluded", sel)
+		}
+
+		if branch.searched() {
+			return fmt.Errorf("invalid select relation %s, searched relations can not be excluded", sel)
+		}
+
+		// a single relation can be used to order the root on
+		prefix := fmt.Sprintf(`"%s".`, branch.as)
+		for _, v := range l.tree.order.orders {
+			if strings.Contains(v.Column, prefix) || strings.Contains(v.parent, prefix) {
+				return fmt.Errorf("invalid select relation %s, ordered relations can not be excluded", sel)
+			}
+		}
+
+		branch.excluded = true
+
+		return nil
+	}
+
+	model, field := l.splitColumn(sel)
+
+	reg, ok := l.registry[model]
+	if !ok {
+		return fmt.Errorf("invalid select field %s", sel)
+	}
+
+	if _, ok = reg.fieldDatabase[field]; !ok {
+		return fmt.Errorf("invalid select field %s", sel)
+	}
+
+	for _, v := range reg.branch.source.PrimaryKeys() {
+		if v == field {
+			return fmt.Errorf("invalid select field %s, primary keys can not be excluded", sel)
+		}
+	}
+
+	if reg.branch == l.tree {
+		for _, v := range reg.branch.orderedFields {
+			if v == field {
+				return fmt.Errorf("invalid select field %s, ordered fields can not be excluded", sel)
+			}
+		}
+	}
+
+	fields := make([]string, 0, len(reg.branch.selectedFields))
+	for _, v := range reg.branch.selectedFields {
+		if v != field {
+			fields = append(fields, v)
+		}
+	}
+
+	reg.branch.selectedFields = fields
+
 	return nil
 }
 
@@ -51,10 +203,10 @@ func (l *Liqu) selectsAsStruct(branch *branch) []string {
 	var out []string
 
 	for _, field := range branch.selectedFields {
-		out = append(out, fmt.Sprintf(`"%s"."%s" AS "%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field], field))
+		out = append(out, fmt.Sprintf(`%s AS "%s"`, l.fieldColumn(branch.as, field), field))
 
 		if len(branch.aggregateFields) > 0 {
-			branch.groupBy.GroupBy(fmt.Sprintf(`"%s"."%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field]))
+			l.groupByField(branch, field)
 		}
 	}
 
@@ -65,13 +217,13 @@ func (l *Liqu) selectsWithAlias(branch *branch) []string {
 	var out []string
 
 	for _, field := range branch.selectedFields {
-		selectField := fmt.Sprintf(`"%s"."%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field])
+		selectField := l.fieldColumn(branch.as, field)
 		if _, ok := branch.distinctFields[field]; ok {
 			selectField = fmt.Sprintf(`DISTINCT(%s)`, selectField)
 		}
 
 		out = append(out, fmt.Sprintf(`%s AS "%s"`, selectField, field))
-		branch.groupBy.GroupBy(fmt.Sprintf(`"%s"."%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field]))
+		l.groupByField(branch, field)
 	}
 
 	return out
@@ -81,8 +233,12 @@ func (l *Liqu) selectsAsObjectPair(branch *branch) []string {
 	var out []string
 
 	for _, field := range branch.selectedFields {
-		out = append(out, fmt.Sprintf(`'%s'`, field), fmt.Sprintf(`"%s"."%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field]))
-		branch.groupBy.GroupBy(fmt.Sprintf(`"%s"."%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field]))
+		l.groupByField(branch, field)
+		if l.fieldHidden(branch.as, field) {
+			continue
+		}
+
+		out = append(out, fmt.Sprintf(`'%s'`, l.fieldKey(branch.as, field)), l.fieldColumn(branch.as, field))
 	}
 
 	return out
@@ -91,42 +247,122 @@ func (l *Liqu) selectsAsObjectPair(branch *branch) []string {
 func (l *Liqu) selectsWithStructAlias(branch *branch) []string {
 	var out []string
 
+	// the anonymous root selects the fields straight into the output, so they are selected under their keys
+	if branch.anonymous {
+		return l.selectsAsKeys(branch)
+	}
+
 	for _, field := range branch.selectedFields {
 		if subQ, ok := branch.subQuery[field]; ok {
-			// todo, not tested yet
-			subQ.And(fmt.Sprintf(`%s.%s="%s"."%s"`, subQ.from, subQ.fieldLocal, branch.source.Table(), l.registry[branch.as].fieldDatabase[subQ.fieldParent]))
+			expression := l.subQueryExpression(branch, subQ)
 
-			if branch.order.HasOrderBy(fmt.Sprintf(`"%s"."%s"`, branch.source.Table(), branch.registry.fieldDatabase[field])) {
-				out = append([]string{fmt.Sprintf(`(%s) AS "%s"`, subQ.Build(), field)}, out...)
+			if branch.order.HasOrderBy(expression) {
+				out = append([]string{fmt.Sprintf(`%s AS "%s"`, expression, field)}, out...)
 			} else {
-				out = appendUnique(out, fmt.Sprintf(`(%s) AS "%s"`, subQ.Build(), field))
+				out = appendUnique(out, fmt.Sprintf(`%s AS "%s"`, expression, field))
 			}
 		} else {
-			if branch.order.HasOrderBy(fmt.Sprintf(`"%s"."%s"`, branch.source.Table(), branch.registry.fieldDatabase[field])) {
-				out = append([]string{fmt.Sprintf(`"%s"."%s" AS "%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field], field)}, out...)
+			if branch.order.HasOrderBy(l.fieldColumn(branch.as, field)) {
+				out = append([]string{fmt.Sprintf(`%s AS "%s"`, l.fieldColumn(branch.as, field), field)}, out...)
 			} else {
-				out = appendUnique(out, fmt.Sprintf(`"%s"."%s" AS "%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field], field))
+				out = appendUnique(out, fmt.Sprintf(`%s AS "%s"`, l.fieldColumn(branch.as, field), field))
 			}
-			branch.groupBy.GroupBy(fmt.Sprintf(`"%s"."%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field]))
+			l.groupByField(branch, field)
+		}
+	}
+
+	for _, field := range l.registry[branch.as].fieldOrder {
+		if !branch.referencedFields[field] {
+			continue
+		}
+
+		if subQ, ok := branch.subQuery[field]; ok {
+			out = appendUnique(out, fmt.Sprintf(`%s AS "%s"`, l.subQueryExpression(branch, subQ), field))
+		} else {
+			out = appendUnique(out, fmt.Sprintf(`%s AS "%s"`, l.fieldColumn(branch.as, field), field))
+			l.groupByField(branch, field)
+		}
+	}
+
+	return out
+}
+
+func (l *Liqu) selectsAsKeys(branch *branch) []string {
+	var out []string
+
+	for _, field := range branch.selectedFields {
+		column := l.fieldColumn(branch.as, field)
+		if subQ, ok := branch.subQuery[field]; ok {
+			column = l.subQueryExpression(branch, subQ)
+		} else {
+			branch.groupBy.GroupBy(column)
+		}
+
+		if l.fieldHidden(branch.as, field) {
+			continue
+		}
+
+		selectField := fmt.Sprintf(`%s AS "%s"`, column, l.fieldKey(branch.as, field))
+		if branch.order.HasOrderBy(column) {
+			out = append([]string{selectField}, out...)
+		} else {
+			out = appendUnique(out, selectField)
 		}
 	}
 
-	for field := range branch.referencedFields {
+	for _, field := range l.registry[branch.as].fieldOrder {
+		if !branch.referencedFields[field] {
+			continue
+		}
+
+		column := l.fieldColumn(branch.as, field)
 		if subQ, ok := branch.subQuery[field]; ok {
-			subQ.And(fmt.Sprintf(`%s.%s="%s"."%s"`, subQ.from, subQ.fieldLocal, branch.source.Table(), l.registry[branch.as].fieldDatabase[subQ.fieldParent]))
-			out = appendUnique(out, fmt.Sprintf(`(%s) AS "%s"`, subQ.Build(), field))
+			column = l.subQueryExpression(branch, subQ)
 		} else {
-			out = appendUnique(out, fmt.Sprintf(`"%s"."%s" AS "%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field], field))
-			branch.groupBy.GroupBy(fmt.Sprintf(`"%s"."%s"`, branch.source.Table(), l.registry[branch.as].fieldDatabase[field]))
+			branch.groupBy.GroupBy(column)
+		}
+
+		if !l.fieldHidden(branch.as, field) {
+			out = appendUnique(out, fmt.Sprintf(`%s AS "%s"`, column, l.fieldKey(branch.as, field)))
 		}
 	}
 
 	return out
 }
 
+// rootObject returns the json object of the root row, the row itself when none of its fields are renamed or hidden.
+func (l *Liqu) rootObject(branch *branch) string {
+	if len(l.registry[branch.as].fieldJSON) == 0 {
+		return fmt.Sprintf(`"%s"`, branch.as)
+	}
+
+	fields := make([]string, 0)
+	for _, field := range branch.selectedFields {
+		fields = appendUnique(fields, field)
+	}
+
+	for _, field := range l.registry[branch.as].fieldOrder {
+		if branch.referencedFields[field] {
+			fields = appendUnique(fields, field)
+		}
+	}
+
+	var pairs []string
+	for _, field := range fields {
+		if l.fieldHidden(branch.as, field) {
+			continue
+		}
+
+		pairs = append(pairs, fmt.Sprintf(`'%s', "%s"."%s"`, l.fieldKey(branch.as, field), branch.as, field))
+	}
+
+	return fmt.Sprintf("jsonb_build_object( %s )", strings.Join(pairs, ", "))
+}
+
 func (l *Liqu) processSelect(model, field string) {
 	if field == "*" {
-		for f := range l.registry[model].fieldTypes {
+		// the fields are selected in the order of the struct, so the query is the same on every run
+		for _, f := range l.registry[model].fieldOrder {
 			// if we select all fields, we don't need to select field that implements the Source interface
 			if _, ok := reflect.New(l.registry[model].fieldTypes[f]).Interface().(Source); ok {
 				continue
@@ -143,7 +379,7 @@ func (l *Liqu) aggregateWithStructAlias(branch *branch) []string {
 	var out []string
 
 	for _, field := range branch.aggregateFields {
-		out = append(out, fmt.Sprintf(`%s("%s"."%s") AS "%s"`, field.Func, branch.source.Table(), l.registry[branch.as].fieldDatabase[field.Field], field.Alias))
+		out = append(out, fmt.Sprintf(`%s(%s) AS "%s"`, field.Func, l.fieldColumn(branch.as, field.Field), field.Alias))
 	}
 
 	return out
@@ -152,17 +388,88 @@ func (l *Liqu) aggregateWithStructAlias(branch *branch) []string {
 func (l *Liqu) aggregateWithAlias(branch *branch) []string {
 	var out []string
 
+	for _, field := range branch.aggregateGroups {
+		out = append(out, fmt.Sprintf(`%s AS "%s"`, l.aggregateGroupColumn(branch, field), l.aggregateGroupKey(branch, field)))
+	}
+
 	for _, field := range branch.aggregateFields {
-		if branch.as == l.tree.as && l.tree.anonymous {
-			out = append(out, fmt.Sprintf(`%s("%s"."%s") AS "%s"`, field.Func, branch.source.Table(), l.registry[branch.as].fieldDatabase[field.Field], field.Alias))
-		} else {
-			out = append(out, fmt.Sprintf(`%s("%s"."%s") AS "%s"`, field.Func, branch.as, field.Field, field.Alias))
-		}
+		out = append(out, fmt.Sprintf(`%s AS "%s"`, l.aggregateExpression(branch, field), field.Alias))
 	}
 
 	return out
 }
 
+// aggregateExpression returns the aggregate as it is computed on top of the branch
+func (l *Liqu) aggregateExpression(branch *branch, field aggregateField) string {
+	if field.Field == "" {
+		return fmt.Sprintf(`%s(*)`, field.Func)
+	}
+
+	if branch.as == l.tree.as && l.tree.anonymous {
+		return fmt.Sprintf(`%s(%s)`, field.Func, l.fieldColumn(branch.as, field.Field))
+	}
+
+	return fmt.Sprintf(`%s("%s"."%s")`, field.Func, branch.as, field.Field)
+}
+
+// aggregateGroupColumn returns the column the aggregates of the branch are grouped on
+func (l *Liqu) aggregateGroupColumn(branch *branch, field string) string {
+	if branch.as == l.tree.as && l.tree.anonymous {
+		return l.fieldColumn(branch.as, field)
+	}
+
+	return fmt.Sprintf(`"%s"."%s"`, branch.as, field)
+}
+
+// parseAggregates adds the aggregates requested like `SUM|Project.Volume|TotalVolume`, the alias is optional.
+// they are computed over the root and have to be allowed through Defaults.AllowAggregate.
+func (l *Liqu) parseAggregates(query string) error {
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+
+	for _, agg := range strings.Split(query, ",") {
+		parts := strings.Split(strings.TrimSpace(agg), "|")
+		if len(parts) != 2 && len(parts) != 3 {
+			return fmt.Errorf("invalid aggregate format: %s", agg)
+		}
+
+		fn := Aggregator(strings.ToUpper(parts[0]))
+		model, field := l.splitColumn(parts[1])
+
+		if model != l.tree.as || !l.aggregateAllowed(model, field, fn) {
+			return fmt.Errorf("invalid aggregate %s", agg)
+		}
+
+		alias := fmt.Sprintf("%s%s%s", fn[:1], strings.ToLower(string(fn[1:])), field)
+		if len(parts) == 3 {
+			alias = parts[2]
+		}
+
+		if !aggregateAliasRegex.MatchString(alias) {
+			return fmt.Errorf("invalid aggregate alias %s", alias)
+		}
+
+		l.processSelect(model, field)
+		l.processSelectAggregate(model, field, alias, fn)
+	}
+
+	return nil
+}
+
+var aggregateAliasRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// aggregate returns the aggregate field registered under the alias
+func (b *branch) aggregate(alias string) (aggregateField, bool) {
+	for _, v := range b.aggregateFields {
+		if v.Alias == alias {
+			return v, true
+		}
+	}
+
+	return aggregateField{}, false
+}
+
 func (l *Liqu) processSelectAggregate(model, field, alias string, funC Aggregator) {
 	l.registry[model].branch.aggregateFields = append(l.registry[model].branch.aggregateFields, aggregateField{
 		Func:  funC,
@@ -103,6 +103,28 @@ func (sq *SubQuery) Limit(limit int) *SubQuery {
 	return sq
 }
 
+// expression renders the sub query related to the column of the parent, without altering the sub query itself
+// so it can be used in the select, the where and the order by clause alike.
+func (sq *SubQuery) expression(parentColumn string) string {
+	q := *sq.query
+
+	conditions := NewConditionBuilder()
+	conditions.conditions = append(conditions.conditions, sq.conditions.conditions...)
+	conditions.AndRaw(fmt.Sprintf(`%s.%s=%s`, sq.from, sq.fieldLocal, parentColumn))
+
+	q.setWhere(conditions.Build())
+
+	if len(sq.joins) > 0 {
+		q.setJoin(strings.Join(sq.joins, " "))
+	}
+
+	return fmt.Sprintf("(%s)", q.Scrub())
+}
+
+func (l *Liqu) subQueryExpression(branch *branch, subQ *SubQuery) string {
+	return subQ.expression(l.fieldColumn(branch.as, subQ.fieldParent))
+}
+
 func (sq *SubQuery) Build() string {
 	sq.query.setWhere(sq.conditions.Build())
 
@@ -0,0 +1,112 @@
+package liqu
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// Total is an aggregate over all rows of the root matching the conditions, whatever page is returned.
+	// the field has to be on the root or on a single valued relation of the root.
+	Total struct {
+		fn     Aggregator
+		name   string
+		alias  string
+		model  string
+		column string
+	}
+)
+
+// Sum totals the values of the column, like `Invoice.Amount`
+func Sum(column string) Total {
+	return Total{fn: AggSum, name: column}
+}
+
+// Avg averages the values of the column
+func Avg(column string) Total {
+	return Total{fn: AggAvg, name: column}
+}
+
+// Min returns the lowest value of the column
+func Min(column string) Total {
+	return Total{fn: AggMin, name: column}
+}
+
+// Max returns the highest value of the column
+func Max(column string) Total {
+	return Total{fn: AggMax, name: column}
+}
+
+// Count counts the values of the column that are not NULL
+func Count(column string) Total {
+	return Total{fn: AggCount, name: column}
+}
+
+// As returns the total under the alias, instead of the function followed by the field like `SumAmount`
+func (t Total) As(alias string) Total {
+	t.alias = alias
+
+	return t
+}
+
+// WithTotals computes the totals over the rows of the root matching the conditions before they are paginated,
+// they are read with Filters.Totals after PostProcess.
+func (l *Liqu) WithTotals(totals ...Total) *Liqu {
+	l.totals = append(l.totals, totals...)
+
+	return l
+}
+
+// resolveTotals validates the totals and resolves their columns on the rows of the root
+func (l *Liqu) resolveTotals() error {
+	aliases := make(map[string]bool)
+
+	for k, v := range l.facet.totals {
+		model, field, column, err := l.facetColumn(strings.TrimSpace(v.name))
+		if err != nil {
+			return fmt.Errorf("invalid total %s: %w", v.name, err)
+		}
+
+		if v.alias == "" {
+			v.alias = fmt.Sprintf("%s%s%s", v.fn[:1], strings.ToLower(string(v.fn[1:])), field)
+		}
+
+		if !aggregateAliasRegex.MatchString(v.alias) || aliases[v.alias] {
+			return fmt.Errorf("invalid total alias %s", v.alias)
+		}
+
+		aliases[v.alias] = true
+
+		v.model, v.column = model, column
+		l.facet.totals[k] = v
+	}
+
+	return nil
+}
+
+// totalOn reports whether one of the totals is on a field of the model
+func (f *facet) totalOn(model string) bool {
+	for _, v := range f.totals {
+		if v.model == model {
+			return true
+		}
+	}
+
+	return false
+}
+
+// totalSelects returns the columns of the totals
+func (l *Liqu) totalSelects() []string {
+	var out []string
+
+	for _, v := range l.facet.totals {
+		out = append(out, fmt.Sprintf(`%s(%s) AS "%s"`, v.fn, v.column, v.alias))
+	}
+
+	return out
+}
+
+// Totals returns the totals requested with WithTotals, keyed by their alias
+func (f *Filters) Totals() map[string]interface{} {
+	return f.totals
+}
@@ -0,0 +1,49 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithTotals(t *testing.T) {
+	filters := &Filters{
+		Where:   "Price.ProductID|=|4",
+		OrderBy: "Price.Amount|DESC",
+		Page:    2,
+		PerPage: 10,
+	}
+
+	li := New(context.TODO(), filters).
+		WithTotals(Sum("Price.Amount"), Avg("Price.Amount").As("AverageAmount"), Count("Price.ID"))
+
+	err := li.FromSource(make([]PriceList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT jsonb_build_object( 'Data', ( SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Price" ) AS "Price" FROM ( SELECT "price"."amount" AS "Amount", "price"."id" AS "ID", "price"."product_id" AS "ProductID" FROM "price" WHERE "price"."product_id" = $1 GROUP BY "price"."amount", "price"."id", "price"."product_id" ORDER BY "price"."amount" DESC, "price"."id" ASC) AS "Price" ORDER BY "Amount" DESC, "ID" ASC LIMIT 10 OFFSET 10 ) q ), 'Totals', ( SELECT coalesce(to_jsonb(q),'{}') FROM ( SELECT SUM("Price"."Amount") AS "SumAmount", AVG("Price"."Amount") AS "AverageAmount", COUNT("Price"."ID") AS "CountID" FROM ( SELECT "price"."id" AS "ID", "price"."product_id" AS "ProductID", "price"."amount" AS "Amount" FROM "price" WHERE "price"."product_id" = $2 GROUP BY "price"."id", "price"."product_id", "price"."amount" ) AS "Price" ) q ) )`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 2 {
+		t.Errorf("expected 2 params, got %d", len(sqlParams))
+	}
+
+	result := li.PostProcess(`{"Data": [{"totalrows": 12, "Price": {"ID": 1}}], "Totals": {"SumAmount": 120.5, "AverageAmount": 10.04, "CountID": 12}}`)
+	if result != `[{ "Price": {"ID": 1}}]` {
+		t.Errorf("expected the rows of the result, got %s", result)
+	}
+
+	if li.Filters().TotalResults() != 12 || li.Filters().Totals()["SumAmount"] != 120.5 || li.Filters().Totals()["CountID"] != 12.0 {
+		t.Errorf("expected the totals next to the total results, got %d and %+v", li.Filters().TotalResults(), li.Filters().Totals())
+	}
+
+	li = New(context.TODO(), nil).WithTotals(Sum("Price.Amount"), Sum("Price.ID").As("SumAmount"))
+	if err = li.FromSource(make([]PriceList, 0)); err == nil {
+		t.Error("expected an error for a duplicate alias")
+	}
+}
@@ -6,12 +6,23 @@ import (
 )
 
 func (l *Liqu) traverse() error {
+	if l.sourceSlice {
+		l.tieBreak(l.tree)
+	}
+
+	if l.facet != nil {
+		err := l.pruneFacet()
+		if err != nil {
+			return err
+		}
+	}
+
 	if l.tree.anonymous {
 		return l.traverseAnonymousRoot()
 	}
 
 	root := newRootQuery()
-	if l.sourceSlice {
+	if l.sourceSlice && l.facet == nil && !l.rows {
 		root.SetTotalRows("count(*) OVER() AS TotalRows,")
 	}
 
@@ -21,10 +32,14 @@ func (l *Liqu) traverse() error {
 	} else {
 		rootFieldSelect = newBranchSingle()
 	}
-	rootFieldSelect.setSelect(fmt.Sprintf(`"%s"`, l.tree.as)).setAs(l.tree.as)
+	rootFieldSelect.setSelect(l.rootObject(l.tree)).setAs(l.tree.jsonKey())
 
-	whereNulls := NewConditionBuilder()
+	whereNulls := l.tree.outerWhere
 	for _, v := range l.tree.branches {
+		if v.excluded {
+			continue
+		}
+
 		// if it is a Cte, we branch of and threat it as a root element with no parent.
 		if v.isCTE {
 			cte, err := l.traverseCteBranch(v, l.tree)
@@ -47,13 +62,24 @@ func (l *Liqu) traverse() error {
 		}
 	}
 
+	relationAggregates := l.traverseRelationAggregates()
+
 	root.setJoin(strings.Join(l.tree.joinBranched, " "))
 
+	distinct, distinctOrder, err := l.distinctOn(l.tree)
+	if err != nil {
+		return err
+	}
+
 	base := newBaseQuery().
+		setDistinct(distinct).
 		setFrom(l.tree.registry.tableName).
 		setSelect(strings.Join(l.selectsWithStructAlias(l.tree), ", "))
 
 	rootSelects := []string{rootFieldSelect.Scrub()}
+	for k, v := range relationAggregates {
+		rootSelects = append(rootSelects, fmt.Sprintf(`%s AS "%s"`, v, l.tree.relationAggregates[k].key))
+	}
 
 	cteGroupBy := NewGroupByBuilder()
 
@@ -71,7 +97,7 @@ func (l *Liqu) traverse() error {
 			}
 			hasSubCTE = true
 		} else {
-			rootSelects = append(rootSelects, fmt.Sprintf(`"%s"."%s" AS "%s"`, v.as, v.field, v.field))
+			rootSelects = append(rootSelects, fmt.Sprintf(`"%s"."%s" AS "%s"`, v.as, v.field, v.key))
 		}
 	}
 
@@ -83,62 +109,70 @@ func (l *Liqu) traverse() error {
 
 	if hasSubCTE {
 		for _, v := range l.tree.branches {
-			if v.isCTE {
+			if v.isCTE || v.excluded {
 				continue
 			}
 			cteGroupBy.GroupBy(fmt.Sprintf(`"%s"`, v.as))
 		}
+
+		for _, v := range relationAggregates {
+			cteGroupBy.GroupBy(v)
+		}
 	}
 
 	var selects []string
 	selects = rootSelects
 	if len(l.tree.aggregateFields) > 0 {
 		selects = l.aggregateWithAlias(l.tree)
+
+		for _, v := range l.tree.aggregateGroups {
+			cteGroupBy.GroupBy(l.aggregateGroupColumn(l.tree, v))
+		}
+	}
+
+	// a facet returns the counts per value instead of the rows
+	var facetOrder string
+	if l.facet != nil {
+		var groups []string
+		selects, groups, facetOrder = l.facetSelects()
+
+		cteGroupBy = NewGroupByBuilder()
+		for _, v := range groups {
+			cteGroupBy.GroupBy(v)
+		}
 	}
 
 	root.setSelect(strings.Join(selects, ", ")).
 		setFrom(base.Scrub()).
 		setAs(l.tree.as).
-		setLimit(l.filters).
+		setLimit(l.paging()).
 		setWhere(l.tree.where.Build()).
 		setWhereNulls(whereNulls.Build())
 
-	if l.tree.order.Build() != "" {
-		eo, err := ExtractOrders(l.tree.order.Build())
-		if err == nil {
-			no := NewOrderBuilder()
-			for _, v := range eo {
-				if l.tree.registry.tableName != v.Table {
-					continue
-				}
-
-				for k, fd := range l.tree.registry.fieldDatabase {
-					if fd == v.Column {
-						if len(cteGroupBy.groups) > 0 {
-							cteGroupBy.GroupBy(fmt.Sprintf(`"%s"`, k))
-						}
-						no.OrderBy(fmt.Sprintf(`"%s"`, k), OrderDirection(v.Direction))
-					}
-				}
-			}
-
-			if len(l.tree.aggregateFields) == 0 {
-				root.setOrderByParent(no.Build())
-			}
-		}
+	switch {
+	case l.facet != nil:
+		root.setOrderByParent(facetOrder)
+	case len(l.tree.aggregateFields) == 0:
+		root.setOrderByParent(l.parentOrder(l.tree, cteGroupBy).Build())
+	default:
+		root.setOrderByParent(l.tree.order.buildOuter())
 	}
 
 	if len(l.tree.aggregateFields) == 0 {
-		root.setOrderBy(l.tree.order.Build())
+		root.setOrderBy(distinctOrder.Build())
 	}
 
 	root.setGroupBy(l.tree.groupBy.Build()).
-		setGroupByCTE(cteGroupBy.Build())
+		setGroupByCTE(cteGroupBy.Build()).
+		setHaving(l.tree.having.Build())
 
 	var wrapper *query
-	if l.sourceSlice {
+	switch {
+	case l.rows:
+		wrapper = newRowQuery()
+	case l.sliceResult():
 		wrapper = newSliceQuery()
-	} else {
+	default:
 		wrapper = newSingleQuery()
 	}
 
@@ -157,12 +191,20 @@ func (l *Liqu) traverse() error {
 }
 
 func (l *Liqu) traverseAnonymousRoot() error {
+	if _, _, err := l.distinctOn(l.tree); err != nil {
+		return err
+	}
+
 	root := newAnonRootQuery()
-	if l.sourceSlice {
+	if l.sourceSlice && l.facet == nil && !l.rows {
 		root.SetTotalRows("count(*) OVER() AS TotalRows,")
 	}
 
 	for _, v := range l.tree.branches {
+		if v.excluded {
+			continue
+		}
+
 		// if it is a Cte, we branch of and threat it as a root element with no parent.
 		if v.isCTE {
 			cte, err := l.traverseCteBranch(v, l.tree)
@@ -185,11 +227,16 @@ func (l *Liqu) traverseAnonymousRoot() error {
 		//}
 	}
 
+	relationAggregates := l.traverseRelationAggregates()
+
 	root.setJoin(strings.Join(l.tree.joinBranched, " "))
 
 	cteGroupBy := NewGroupByBuilder()
 
 	var selects = l.selectsWithStructAlias(l.tree)
+	for k, v := range relationAggregates {
+		selects = append(selects, fmt.Sprintf(`%s AS "%s"`, v, l.tree.relationAggregates[k].key))
+	}
 
 	var hasSubCTE bool
 	for _, v := range l.tree.joinFields {
@@ -205,7 +252,7 @@ func (l *Liqu) traverseAnonymousRoot() error {
 			}
 			hasSubCTE = true
 		} else {
-			selects = append(selects, fmt.Sprintf(`"%s"."%s" AS "%s"`, v.as, v.field, v.field))
+			selects = append(selects, fmt.Sprintf(`"%s"."%s" AS "%s"`, v.as, v.field, v.key))
 		}
 	}
 
@@ -217,7 +264,7 @@ func (l *Liqu) traverseAnonymousRoot() error {
 
 	if hasSubCTE {
 		for _, v := range l.tree.branches {
-			if v.isCTE {
+			if v.isCTE || v.excluded {
 				continue
 			}
 			cteGroupBy.GroupBy(fmt.Sprintf(`"%s"`, v.as))
@@ -228,39 +275,43 @@ func (l *Liqu) traverseAnonymousRoot() error {
 		selects = l.aggregateWithAlias(l.tree)
 	}
 
+	if l.facet != nil {
+		var (
+			groups     []string
+			facetOrder string
+		)
+		selects, groups, facetOrder = l.facetSelects()
+
+		root.setGroupBy(strings.Join(groups, ", ")).
+			setOrderBy(facetOrder)
+	}
+
 	root.setSelect(strings.Join(selects, ", ")).
 		setFrom(fmt.Sprintf(`"%s"`, l.tree.registry.tableName)).
 		setAs(l.tree.as).
-		setLimit(l.filters).
+		setLimit(l.paging()).
 		setWhere(l.tree.where.Build())
 
-	if l.tree.order.Build() != "" {
-		eo, err := ExtractOrders(l.tree.order.Build())
-		if err == nil {
-			no := NewOrderBuilder()
-			for _, v := range eo {
-				if l.tree.registry.tableName != v.Table {
-					continue
-				}
-
-				for k, fd := range l.tree.registry.fieldDatabase {
-					if fd == v.Column {
-						if len(cteGroupBy.groups) > 0 {
-							cteGroupBy.GroupBy(fmt.Sprintf(`"%s"`, k))
-						}
-						no.OrderBy(fmt.Sprintf(`"%s"`, k), OrderDirection(v.Direction))
-					}
-				}
-			}
-
-			if len(l.tree.aggregateFields) == 0 {
-				root.setOrderByParent(no.Build())
-			}
-		}
+	parentOrder := l.parentOrder(l.tree, cteGroupBy)
+	if len(l.tree.aggregateFields) == 0 {
+		root.setOrderByParent(parentOrder.Build())
 	}
 
 	if len(l.tree.aggregateFields) == 0 {
 		root.setOrderBy(l.tree.order.Build())
+	} else {
+		root.setOrderBy(l.tree.order.buildOuter())
+	}
+
+	root.setHaving(l.tree.having.Build())
+
+	if len(l.tree.aggregateFields) > 0 {
+		groupBy := NewGroupByBuilder()
+		for _, v := range l.tree.aggregateGroups {
+			groupBy.GroupBy(l.aggregateGroupColumn(l.tree, v))
+		}
+
+		root.setGroupBy(groupBy.Build())
 	}
 
 	//root.setGroupBy(l.tree.groupBy.Build())
@@ -268,9 +319,12 @@ func (l *Liqu) traverseAnonymousRoot() error {
 	//root.setGroupByCTE(cteGroupBy.Build())
 
 	var wrapper *query
-	if l.sourceSlice {
+	switch {
+	case l.rows:
+		wrapper = newRowQuery()
+	case l.sliceResult():
 		wrapper = newSliceQuery()
-	} else {
+	default:
 		wrapper = newSingleQuery()
 	}
 
@@ -293,12 +347,23 @@ func (l *Liqu) traverseBranch(branch *branch, parent *branch) error {
 		return nil
 	}
 
-	base := newBaseQuery().setFrom(branch.registry.tableName)
-
 	var (
 		selects = make([]string, 0)
 	)
 
+	// only a limited slice needs a stable order, a single relation has just the one row
+	if branch.slice && branch.limit != nil {
+		l.tieBreak(branch)
+	}
+
+	distinct, distinctOrder, err := l.distinctOn(branch)
+	if err != nil {
+		return err
+	}
+
+	// the rows are picked before they are aggregated when they are limited or distinct
+	limited := branch.slice && (branch.limit != nil || distinct != "")
+
 	branchFieldSelect := newBranchAnon()
 	if !branch.anonymous {
 		if branch.slice {
@@ -312,10 +377,15 @@ func (l *Liqu) traverseBranch(branch *branch, parent *branch) error {
 	parent.joinFields = append(parent.joinFields, branchJoinField{
 		table: branch.source.Table(),
 		field: branch.as,
+		key:   branch.jsonKey(),
 		as:    branch.as,
 	})
 
 	for _, v := range branch.branches {
+		if v.excluded {
+			continue
+		}
+
 		err := l.traverseBranch(v, branch)
 		if err != nil {
 			return err
@@ -323,7 +393,7 @@ func (l *Liqu) traverseBranch(branch *branch, parent *branch) error {
 	}
 
 	for _, v := range branch.joinFields {
-		selects = append(selects, fmt.Sprintf(`'%s', "%s"."%s"`, v.field, v.as, v.field))
+		selects = append(selects, fmt.Sprintf(`'%s', "%s"."%s"`, v.key, v.as, v.field))
 	}
 
 	branchFieldSelect.setSelect(fmt.Sprintf("jsonb_build_object( %s )", strings.Join(selects, ", "))).setAs(branch.as)
@@ -339,6 +409,8 @@ func (l *Liqu) traverseBranch(branch *branch, parent *branch) error {
 				if l.tree.as == v.externalTable {
 					externalField = fmt.Sprintf(`%s`, v.externalField)
 					externalTable = v.externalTable
+					// the lateral join reads the field from the wrapped root, so it has to be selected even when it was not asked for
+					l.tree.referencedFields[v.externalField] = true
 				}
 			}
 		} else {
@@ -355,6 +427,11 @@ func (l *Liqu) traverseBranch(branch *branch, parent *branch) error {
 
 	selectsWithReferences := make([]string, 0)
 	for k := range branch.referencedFields {
+		if expression, ok := branch.registry.fieldExpression[k]; ok {
+			selectsWithReferences = append(selectsWithReferences, fmt.Sprintf(`(%s) AS "%s"`, expression, branch.registry.fieldDatabase[k]))
+			continue
+		}
+
 		selectsWithReferences = append(selectsWithReferences, branch.registry.fieldDatabase[k])
 	}
 
@@ -373,16 +450,9 @@ func (l *Liqu) traverseBranch(branch *branch, parent *branch) error {
 		}
 	}
 
-	base.setSelect(strings.Join(selectsWithReferences, ", ")).
-		setJoin(strings.Join(branch.joinBranched, " ")).
-		setWhere(branch.where.Build())
-
-	if branch.parent.isCTE {
-		base.setGroupBy(branch.groupBy.Build())
-	}
-
+	var filters *Filters
 	if branch.limit != nil {
-		filters := &Filters{
+		filters = &Filters{
 			Page:    1,
 			PerPage: *branch.limit,
 		}
@@ -390,8 +460,33 @@ func (l *Liqu) traverseBranch(branch *branch, parent *branch) error {
 		if branch.offset != nil {
 			filters.Page = *branch.offset
 		}
+	}
+
+	var base *query
+	if limited {
+		// the rows are limited before they are aggregated, limiting the aggregate itself would always return the one row
+		rows := newLimitedQuery().
+			setDistinct(distinct).
+			setFrom(branch.registry.tableName).
+			setWhere(branch.where.Build()).
+			setOrderBy(distinctOrder.Build()).
+			setLimit(filters)
 
-		base.setLimit(filters)
+		base = newBaseLimitedQuery().
+			setFrom(rows.Scrub()).
+			setAs(branch.registry.tableName)
+	} else {
+		base = newBaseQuery().
+			setFrom(branch.registry.tableName).
+			setWhere(branch.where.Build()).
+			setLimit(filters)
+	}
+
+	base.setSelect(strings.Join(selectsWithReferences, ", ")).
+		setJoin(strings.Join(branch.joinBranched, " "))
+
+	if branch.parent.isCTE {
+		base.setGroupBy(branch.groupBy.Build())
 	}
 
 	parent.joinBranched = append(
@@ -435,3 +530,47 @@ func (l *Liqu) applyCTEonBranch(branch *branch, linkedCte linkedCte) {
 		branch.where.AndRaw(fmt.Sprintf(`"%s"."%s" IN (%s)`, branch.source.Table(), branch.registry.fieldDatabase[linkedCte.field], baseQuery.Scrub()))
 	}
 }
+
+// tieBreak appends the primary keys to the order of the branch, so rows sharing the same values are returned
+// in the same order on every request and pages neither overlap nor skip rows. without an order the rows are
+// ordered on the primary keys alone.
+func (l *Liqu) tieBreak(branch *branch) {
+	if l.noTieBreaker {
+		return
+	}
+
+	// grouped rows are told apart by their groups
+	if len(branch.aggregateFields) > 0 {
+		for _, field := range branch.aggregateGroups {
+			alias := fmt.Sprintf(`"%s"`, l.aggregateGroupKey(branch, field))
+			if branch.anonymous {
+				alias = l.fieldColumn(branch.as, field)
+			}
+
+			if branch.order.HasOrderBy(alias) {
+				continue
+			}
+
+			branch.order.order(Order{
+				Direction: Asc,
+				parent:    alias,
+			})
+		}
+
+		return
+	}
+
+	for _, pk := range l.primaryKeys(branch.registry.fieldDatabase, branch.source) {
+		column := fmt.Sprintf(`"%s"."%s"`, branch.registry.tableName, branch.registry.fieldDatabase[pk])
+		if branch.order.HasOrderBy(column) {
+			continue
+		}
+
+		branch.order.order(Order{
+			Column:    column,
+			Direction: Asc,
+			parent:    fmt.Sprintf(`"%s"`, pk),
+		})
+		branch.groupBy.GroupBy(column)
+	}
+}
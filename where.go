@@ -31,8 +31,19 @@ const (
 	StartsWith         Operator = "^"
 	IsNull             Operator = "IS NULL"
 	IsNotNull          Operator = "IS NOT NULL"
+	IsDistinctFrom     Operator = "IS DISTINCT FROM"
+	IsNotDistinctFrom  Operator = "IS NOT DISTINCT FROM"
+	Date               Operator = "date"
+	Has                Operator = "has"
+	HasNot             Operator = "hasnot"
+	Within             Operator = "within"
+	BBox               Operator = "bbox"
 )
 
+// NullValue is the value that compares with NULL in the url grammar, `Field|=|\null` becomes `Field IS NULL`.
+// a literal `\null` string is passed as `\\null`.
+const NullValue = `\null`
+
 func (o Operator) String() string {
 	return string(o)
 }
@@ -41,6 +52,11 @@ func (o Operator) IsIn() bool {
 	return o == In || o == NotIn || o == Any || o == NotAny
 }
 
+// single reports whether the operator compares with a single value, a list would be compared as a row.
+func (o Operator) single() bool {
+	return o == IsDistinctFrom || o == IsNotDistinctFrom
+}
+
 func (o Operator) IsLike() bool {
 	return o == Like || o == ILike || o == NotLike || o == NotILike
 }
@@ -58,6 +74,29 @@ func (o Operator) WrapLike(s string) string {
 	return s
 }
 
+// resolveNull turns a comparison with NullValue into a null check and unescapes `\\null`
+func resolveNull(op Operator, value string) (Operator, interface{}, error) {
+	if op.single() && strings.Contains(value, "--") {
+		return op, nil, fmt.Errorf("operator %s compares with a single value, got %s", op, value)
+	}
+
+	switch value {
+	case NullValue:
+		switch op {
+		case Equal, IsNotDistinctFrom:
+			return IsNull, nil, nil
+		case NotEqual, IsDistinctFrom:
+			return IsNotNull, nil, nil
+		}
+
+		return op, nil, fmt.Errorf("operator %s can not be compared with null", op)
+	case `\` + NullValue:
+		return op, NullValue, nil
+	}
+
+	return op, value, nil
+}
+
 // ConditionBuilder is a struct for fluently building SQL WHERE clauses
 type ConditionBuilder struct {
 	column           string
@@ -66,6 +105,7 @@ type ConditionBuilder struct {
 	counter          int
 	liqu             *Liqu
 	protectedColumns map[string]bool
+	valueWrap        string
 }
 
 // NewConditionBuilder initializes and returns a new ConditionBuilder
@@ -103,6 +143,11 @@ func (cb *ConditionBuilder) Condition(op Operator, value interface{}) *Condition
 			values = append(values, any(v))
 		}
 
+		if op == Between && len(values) == 2 {
+			cb.conditions = append(cb.conditions, fmt.Sprintf("%s %s %s AND %s", cb.column, op, cb.bind(values[0]), cb.bind(values[1])))
+			return cb
+		}
+
 		return cb.multiValueCondition(cb.column, op, values)
 	} else if op.IsIn() {
 		return cb.multiValueCondition(cb.column, op, []interface{}{value})
@@ -120,20 +165,35 @@ func (cb *ConditionBuilder) Condition(op Operator, value interface{}) *Condition
 			value = op.WrapLike(fmt.Sprintf("%s", value))
 		}
 
-		cb.args = append(cb.args, value)
-		cb.counter++
-		if cb.liqu != nil {
-			cb.liqu.sqlParams = append(cb.liqu.sqlParams, value)
-			condition = fmt.Sprintf("%s %s $%d", cb.column, op, len(cb.liqu.sqlParams))
-		} else {
-			condition = fmt.Sprintf("%s %s $%d", cb.column, op, cb.counter)
-		}
+		condition = fmt.Sprintf("%s %s %s", cb.column, op, cb.bind(value))
 	}
 
 	cb.conditions = append(cb.conditions, condition)
 	return cb
 }
 
+// bind registers the value as a parameter and returns its placeholder
+func (cb *ConditionBuilder) bind(value interface{}) string {
+	cb.args = append(cb.args, value)
+	cb.counter++
+
+	if cb.liqu != nil {
+		cb.liqu.sqlParams = append(cb.liqu.sqlParams, value)
+		return cb.wrapValue(fmt.Sprintf("$%d", len(cb.liqu.sqlParams)))
+	}
+
+	return cb.wrapValue(fmt.Sprintf("$%d", cb.counter))
+}
+
+// wrapValue applies the value wrap, like unaccent(lower(%s)), to the placeholder
+func (cb *ConditionBuilder) wrapValue(placeholder string) string {
+	if cb.valueWrap == "" {
+		return placeholder
+	}
+
+	return fmt.Sprintf(cb.valueWrap, placeholder)
+}
+
 // And adds an AND condition with the provided column, operator, and value
 func (cb *ConditionBuilder) And(column string, op Operator, value interface{}) *ConditionBuilder {
 	if len(cb.conditions) > 0 {
@@ -152,6 +212,16 @@ func (cb *ConditionBuilder) AndRaw(raw string) *ConditionBuilder {
 	return cb
 }
 
+func (cb *ConditionBuilder) OrRaw(raw string) *ConditionBuilder {
+	if len(cb.conditions) > 0 {
+		cb.conditions = append(cb.conditions, Or.String())
+	}
+
+	cb.conditions = append(cb.conditions, raw)
+
+	return cb
+}
+
 // Or adds an OR condition with the provided column, operator, and value
 func (cb *ConditionBuilder) Or(column string, op Operator, value interface{}) *ConditionBuilder {
 	if len(cb.conditions) > 0 {
@@ -315,10 +385,10 @@ func (cb *ConditionBuilder) multiValueCondition(column string, op Operator, valu
 		if cb.liqu != nil {
 			cb.counter++
 			cb.liqu.sqlParams = append(cb.liqu.sqlParams, value)
-			placeholders[i] = fmt.Sprintf("$%d", len(cb.liqu.sqlParams))
+			placeholders[i] = cb.wrapValue(fmt.Sprintf("$%d", len(cb.liqu.sqlParams)))
 		} else {
 			cb.counter++
-			placeholders[i] = fmt.Sprintf("$%d", cb.counter)
+			placeholders[i] = cb.wrapValue(fmt.Sprintf("$%d", cb.counter))
 		}
 	}
 
@@ -338,6 +408,35 @@ func (cb *ConditionBuilder) Args() []interface{} {
 	return cb.args
 }
 
+// nested returns an empty builder for the conditions of a group, sharing the protected columns
+func (cb *ConditionBuilder) nested() *ConditionBuilder {
+	if cb == nil {
+		return nil
+	}
+
+	nested := NewConditionBuilder().setCounter(cb.counter).setLiqu(cb.liqu)
+	nested.protectedColumns = cb.protectedColumns
+
+	return nested
+}
+
+// group adds the conditions of the nested builder between parentheses and returns the builder
+func (cb *ConditionBuilder) group(op Operator, nested *ConditionBuilder) *ConditionBuilder {
+	if cb == nil || len(nested.conditions) == 0 {
+		return cb
+	}
+
+	raw := fmt.Sprintf("(%s)", nested.Build())
+	cb.args = append(cb.args, nested.args...)
+	cb.setCounter(nested.counter)
+
+	if op == And {
+		return cb.AndRaw(raw)
+	}
+
+	return cb.OrRaw(raw)
+}
+
 func (cb *ConditionBuilder) setCounter(c int) *ConditionBuilder {
 	cb.counter = c
 
@@ -416,12 +515,13 @@ func parseNestedConditions(query string, cb *ConditionBuilder, outerOperator Ope
 			operator := Operator(element[1])
 
 			if len(element) == 3 {
-				var value interface{}
-				value = element[2]
+				operator, value, err := resolveNull(operator, element[2])
+				if err != nil {
+					return nil, err
+				}
+
 				if strings.Contains(element[2], "--") {
 					value = strings.Split(element[2], "--")
-				} else {
-					value = element[2]
 				}
 
 				if outerOperator == And {
@@ -442,7 +542,7 @@ func parseNestedConditions(query string, cb *ConditionBuilder, outerOperator Ope
 	return cb, nil
 }
 
-func (l *Liqu) parseNestedConditions(query string, cb *ConditionBuilder, outerOperator Operator) error {
+func (l *Liqu) parseNestedConditions(query string, outerOperator Operator) error {
 	if strings.TrimSpace(query) == "" {
 		return nil
 	}
@@ -485,16 +585,7 @@ func (l *Liqu) parseNestedConditions(query string, cb *ConditionBuilder, outerOp
 			// Remove the last closing parenthesis
 			nestedQuery = strings.TrimSuffix(nestedQuery, ")")
 
-			var err error
-			if outerOperator == And {
-				cb.AndNested(func(nestedCB *ConditionBuilder) {
-					err = l.parseNestedConditions(nestedQuery, nestedCB, nestedOperator)
-				})
-			} else {
-				cb.OrNested(func(nestedCB *ConditionBuilder) {
-					err = l.parseNestedConditions(nestedQuery, nestedCB, nestedOperator)
-				})
-			}
+			err := l.nestedConditions(nestedQuery, outerOperator, nestedOperator)
 			if err != nil {
 				return fmt.Errorf("[liqu] error in nested query: %s", err.Error())
 			}
@@ -518,6 +609,45 @@ func (l *Liqu) parseNestedConditions(query string, cb *ConditionBuilder, outerOp
 	return nil
 }
 
+// nestedConditions parses the conditions of a group into a builder of its own per branch, the group is added to the
+// conditions of every branch it has conditions on between parentheses. a group on several branches is split into a
+// group per branch, which end up in different queries and so are combined with AND.
+func (l *Liqu) nestedConditions(query string, outerOperator, nestedOperator Operator) error {
+	type builders struct {
+		where      *ConditionBuilder
+		outerWhere *ConditionBuilder
+		having     *ConditionBuilder
+	}
+
+	saved := make(map[*branch]builders)
+	for _, reg := range l.registry {
+		b := reg.branch
+		if b == nil {
+			continue
+		}
+
+		if _, ok := saved[b]; ok {
+			continue
+		}
+
+		saved[b] = builders{where: b.where, outerWhere: b.outerWhere, having: b.having}
+
+		b.where = b.where.nested()
+		b.outerWhere = b.outerWhere.nested()
+		b.having = b.having.nested()
+	}
+
+	err := l.parseNestedConditions(query, nestedOperator)
+
+	for b, v := range saved {
+		b.where = v.where.group(outerOperator, b.where)
+		b.outerWhere = v.outerWhere.group(outerOperator, b.outerWhere)
+		b.having = v.having.group(outerOperator, b.having)
+	}
+
+	return err
+}
+
 func (l *Liqu) processWhere(outerOperator Operator, col string, op string, val interface{}, protect bool) error {
 	var (
 		cteTable string
@@ -540,6 +670,41 @@ func (l *Liqu) processWhere(outerOperator Operator, col string, op string, val i
 		model = l.tree.as
 		field = col
 	}
+	field = l.resolveField(model, field)
+
+	// a facet is counted without its own conditions, the ones of the defaults still hold. within an OR group the
+	// condition holds as TRUE, leaving it out would narrow the group to its other conditions.
+	if !protect && l.facetExcluded(model, field) {
+		if outerOperator == Or {
+			l.registry[model].branch.where.OrRaw("TRUE")
+		}
+
+		return nil
+	}
+
+	if sval, ok := val.(string); ok {
+		operator, value, err := resolveNull(Operator(op), sval)
+		if err != nil {
+			return fmt.Errorf("invalid search field %s: %w", col, err)
+		}
+
+		op, val = string(operator), value
+	}
+
+	if agg, ok := l.relationAggregate(model, field); ok {
+		return l.processRelationAggregateWhere(outerOperator, agg, Operator(op), val)
+	}
+
+	if l.registry[model].fieldWindow[field] {
+		return l.processWindowWhere(outerOperator, model, field, Operator(op), val)
+	}
+
+	// aggregates are filtered after grouping
+	if branch := l.registry[model].branch; branch != nil && branch.having != nil {
+		if agg, ok := branch.aggregate(field); ok {
+			return l.processHaving(outerOperator, branch, agg, Operator(op), val, protect)
+		}
+	}
 
 	var ok bool
 
@@ -547,11 +712,25 @@ func (l *Liqu) processWhere(outerOperator Operator, col string, op string, val i
 		return fmt.Errorf("invalid search field %s", col)
 	}
 
-	// no need to process protected fields.
-	if l.registry[model].branch.where.IsProtected(column) {
+	// no need to process protected fields, defaults can hold multiple conditions on the same field.
+	if !protect && l.registry[model].branch.where.IsProtected(column) {
 		return nil
 	}
 
+	if operator := Operator(op); operator == Has || operator == HasNot {
+		compare := Equal
+		if sval, ok := val.(string); ok && strings.Contains(sval, "--") {
+			compare = In
+		}
+
+		return l.processExists(outerOperator, model, field, column, operator == Has, compare, val, false)
+	}
+
+	// filter the root on the relation, but keep returning all of its children
+	if branch := l.registry[model].branch; branch.slice && !branch.isCTE && branch != l.tree && l.childrenMode(branch) == ChildrenAll {
+		return l.processExists(outerOperator, model, field, column, true, Operator(op), val, true)
+	}
+
 	var tableColumn string
 	if l.registry[model].branch.isCTE {
 		if cteTable == "" {
@@ -560,8 +739,11 @@ func (l *Liqu) processWhere(outerOperator Operator, col string, op string, val i
 
 		l.cte[model].isSearched = true
 		tableColumn = fmt.Sprintf(`"%s"."%s"`, l.registry[model].tableName, column)
+	} else if subQ, ok := l.registry[model].branch.subQuery[field]; ok {
+		// a sub query field is not a column, so we filter on the sub query itself
+		tableColumn = l.subQueryExpression(l.registry[model].branch, subQ)
 	} else {
-		tableColumn = fmt.Sprintf(`"%s"."%s"`, l.registry[model].tableName, column)
+		tableColumn = l.fieldColumn(model, field)
 	}
 
 	operator := Operator(op)
@@ -569,31 +751,177 @@ func (l *Liqu) processWhere(outerOperator Operator, col string, op string, val i
 	l.registry[model].branch.selectedFields = appendUnique(l.registry[model].branch.selectedFields, field)
 	l.registry[model].branch.isSearched = true
 
+	err := l.condition(l.registry[model].branch.where, outerOperator, model, field, tableColumn, operator, val)
+	if err != nil {
+		return fmt.Errorf("invalid search field %s: %w", col, err)
+	}
+
+	if protect {
+		l.registry[model].branch.where.ProtectColumn(column)
+	}
+
+	return nil
+}
+
+// processExists filters the parent of a relation on the existence of related rows through EXISTS (...),
+// unlike a regular search it neither changes the join of the relation nor narrows the rows it returns. with onRoot
+// the root is filtered instead, through an EXISTS on every relation in between.
+func (l *Liqu) processExists(outerOperator Operator, model, field, column string, exists bool, compare Operator, val interface{}, onRoot bool) error {
+	branch := l.registry[model].branch
+	if branch == l.tree || branch.parent == nil || branch.isCTE || len(branch.relations) == 0 {
+		return fmt.Errorf("invalid search field %s.%s, existence checks require a relation", model, field)
+	}
+
+	inner := NewConditionBuilder().setLiqu(l)
+	if err := l.relateToParent(inner, branch); err != nil {
+		return fmt.Errorf("invalid search field %s.%s, %w", model, field, err)
+	}
+
+	// without a value we only check if there is any related row
+	if sval, ok := val.(string); compare == IsNull || compare == IsNotNull || (val != nil && (!ok || sval != "")) {
+		err := l.condition(inner, And, model, field, l.fieldColumn(model, field), compare, val)
+		if err != nil {
+			return fmt.Errorf("invalid search field %s.%s: %w", model, field, err)
+		}
+	}
+
+	condition := fmt.Sprintf(`EXISTS (SELECT 1 FROM "%s" WHERE %s)`, l.registry[model].tableName, inner.Build())
+
+	target := branch.parent
+	for onRoot && target != l.tree {
+		if target.isCTE {
+			return fmt.Errorf("invalid search field %s.%s, existence checks can not pass the cte %s", model, field, target.as)
+		}
+
+		outer := NewConditionBuilder().setLiqu(l)
+		if err := l.relateToParent(outer, target); err != nil {
+			return fmt.Errorf("invalid search field %s.%s, %w", model, field, err)
+		}
+
+		outer.AndRaw(condition)
+		condition = fmt.Sprintf(`EXISTS (SELECT 1 FROM "%s" WHERE %s)`, l.registry[target.as].tableName, outer.Build())
+		target = target.parent
+	}
+
+	if !exists {
+		condition = fmt.Sprintf("NOT %s", condition)
+	}
+
+	if outerOperator == And {
+		target.where.AndRaw(condition)
+	} else {
+		target.where.OrRaw(condition)
+	}
+
+	return nil
+}
+
+// relateToParent adds the conditions relating the rows of the branch to the ones of its parent to cb.
+func (l *Liqu) relateToParent(cb *ConditionBuilder, branch *branch) error {
+	if len(branch.relations) == 0 {
+		return fmt.Errorf("existence checks require a relation on %s", branch.as)
+	}
+
+	reg := l.registry[branch.as]
+	for _, v := range branch.relations {
+		if !v.parent {
+			return errors.New("existence checks require a relation to the parent")
+		}
+
+		external := l.registry[v.externalTable]
+		cb.AndRaw(fmt.Sprintf(`"%s"."%s" %s "%s"."%s"`,
+			reg.tableName,
+			reg.fieldDatabase[v.localField],
+			v.operator,
+			external.tableName,
+			external.fieldDatabase[v.externalField],
+		))
+	}
+
+	return nil
+}
+
+// processHaving filters on an aggregate of the root, which ends up in the HAVING clause of the query. an aggregate of
+// a relation of the root filters the root through a correlated query computing it per row, like the categories with
+// more than 3 articles.
+func (l *Liqu) processHaving(outerOperator Operator, branch *branch, agg aggregateField, op Operator, val interface{}, protect bool) error {
+	if !protect && branch.having.IsProtected(agg.Alias) {
+		return nil
+	}
+
+	// the type of the field only holds for aggregates returning the value itself
+	field := ""
+	if agg.Func == AggMin || agg.Func == AggMax {
+		field = agg.Field
+	}
+
+	cb, column := branch.having, l.aggregateExpression(branch, agg)
+	if branch != l.tree {
+		ra, err := l.childAggregate(branch, agg)
+		if err != nil {
+			return fmt.Errorf("invalid search field %s.%s: %w", branch.as, agg.Alias, err)
+		}
+
+		cb, column = l.tree.where, fmt.Sprintf("(%s)", l.relationAggregateQuery(ra, false))
+	}
+
+	err := l.condition(cb, outerOperator, branch.as, field, column, op, val)
+	if err != nil {
+		return fmt.Errorf("invalid search field %s: %w", agg.Alias, err)
+	}
+
+	if protect {
+		branch.having.ProtectColumn(agg.Alias)
+	}
+
+	return nil
+}
+
+// condition adds a single comparison on the column to cb, values are coerced according to the type of the field.
+func (l *Liqu) condition(cb *ConditionBuilder, outerOperator Operator, model, field, column string, op Operator, val interface{}) error {
 	if val != nil {
-		var value interface{}
-		value = val
 		sval := fmt.Sprintf("%s", val)
 		if strings.Contains(sval, "--") {
-			value = strings.Split(sval, "--")
-		} else {
-			value = val
+			val = strings.Split(sval, "--")
 		}
+	}
 
-		if outerOperator == And {
-			l.registry[model].branch.where.And(tableColumn, operator, value)
-		} else {
-			l.registry[model].branch.where.Or(tableColumn, operator, value)
+	if v := reflect.ValueOf(val); op.single() && v.Kind() == reflect.Slice {
+		return fmt.Errorf("operator %s compares with a single value", op)
+	}
+
+	if op == Within || op == BBox {
+		return l.geoCondition(cb, outerOperator, column, op, val)
+	}
+
+	if op == Date || isTimeType(l.registry[model].fieldTypes[field]) {
+		handled, err := l.dateCondition(cb, outerOperator, column, op, val)
+		if err != nil {
+			return err
 		}
-	} else {
-		if outerOperator == And {
-			l.registry[model].branch.where.And(tableColumn, operator, nil)
-		} else {
-			l.registry[model].branch.where.Or(tableColumn, operator, nil)
+
+		if handled {
+			return nil
 		}
 	}
 
-	if protect {
-		l.registry[model].branch.where.ProtectColumn(column)
+	if op == Date {
+		return fmt.Errorf("operator %s requires a date value", op)
+	}
+
+	if n, ok := l.registry[model].fieldNormalize[field]; ok {
+		column = n.column(column)
+
+		cb.valueWrap = n.value()
+		defer func() {
+			cb.valueWrap = ""
+		}()
+	}
+
+	if outerOperator == And {
+		cb.And(column, op, val)
+	} else {
+		cb.Or(column, op, val)
 	}
 
 	return nil
@@ -1,6 +1,7 @@
 package liqu
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"testing"
@@ -97,6 +98,38 @@ func TestParseURLQueryToConditionBuilderNestedOR(t *testing.T) {
 	}
 }
 
+func TestParseURLQueryToConditionBuilderNull(t *testing.T) {
+	urlQuery := url.Values{}
+	urlQuery.Set("where", `status|IS DISTINCT FROM|done,deleted_at|=|\null,owner|<>|\null,code|=|\\null`)
+
+	cb, err := ParseURLQueryToConditionBuilder(urlQuery.Get("where"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	whereClause := cb.Build()
+	expected := `status IS DISTINCT FROM $1 AND deleted_at IS NULL AND owner IS NOT NULL AND code = $2`
+	if expected != whereClause {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, whereClause)
+	}
+
+	whereArgs := cb.Args()
+	if len(whereArgs) != 2 || whereArgs[1] != `\null` {
+		t.Errorf("expected the escaped null to be passed as a literal, got %v", whereArgs)
+	}
+
+	_, err = ParseURLQueryToConditionBuilder(`status|>|\null`)
+	if err == nil {
+		t.Error("expected an error comparing null with >")
+	}
+
+	_, err = ParseURLQueryToConditionBuilder(`status|IS DISTINCT FROM|a--b`)
+	if err == nil {
+		t.Error("expected an error comparing a list with IS DISTINCT FROM")
+	}
+}
+
 func TestLikeWrapSearched(t *testing.T) {
 	cb := NewConditionBuilder()
 	whereClause := cb.Column("name").
@@ -122,3 +155,77 @@ func TestLikeWrapSearched(t *testing.T) {
 		t.Errorf("expected:\n%s\ngot:\n%s", "%John%", cb.Args()[0])
 	}
 }
+
+func TestWhereNormalized(t *testing.T) {
+	filters := &Filters{
+		Select:  "Project.ID",
+		Where:   "Project.Name|~~*|Muller,Project.Description|=|Cafe",
+		OrderBy: "Project.Name|DESC",
+	}
+
+	def := NewDefaults().
+		Unaccent("Project.Name").
+		Collate("Project.Description", "und-x-icu")
+
+	li := New(context.TODO(), filters).WithDefaults(def)
+
+	err := li.FromSource(make([]Single, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Project" ) AS "Project" FROM ( SELECT "project"."name" AS "Name", "project"."id" AS "ID" FROM "project" WHERE unaccent(lower("project"."name")) ~~* unaccent(lower($1)) AND "project"."description" COLLATE "und-x-icu" = $2 GROUP BY "project"."name", "project"."id" ORDER BY unaccent(lower("project"."name")) DESC, "project"."id" ASC) AS "Project" ORDER BY unaccent(lower("Name")) DESC, "ID" ASC LIMIT 25 OFFSET 0 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 2 || sqlParams[0] != "%Muller%" {
+		t.Errorf("expected 2 params starting with %%Muller%%, got %v", sqlParams)
+	}
+}
+
+type ProjectNote struct {
+	ID    int    `db:"id"`
+	Title string `db:"title" liqu:"unaccent;collate:und-x-icu"`
+	Note  string `db:"note" liqu:"-;unaccent"`
+}
+
+func (*ProjectNote) Table() string {
+	return "project_note"
+}
+
+func (*ProjectNote) PrimaryKeys() []string {
+	return []string{"id"}
+}
+
+func TestLiquTagOptions(t *testing.T) {
+	type ProjectNoteList struct {
+		Project      Project
+		ProjectNotes []ProjectNote `liqu:"cte;collate:und-x-icu" related:"ProjectNotes.ID=Project.ID" join:"left"`
+	}
+
+	li := New(context.TODO(), nil)
+
+	err := li.FromSource(make([]ProjectNoteList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the options are keys of the tag, so they hold next to other options
+	reg := li.registry["ProjectNotes"]
+	if !reg.branch.isCTE {
+		t.Error("expected the relation to be a cte")
+	}
+
+	if _, ok := reg.fieldDatabase["Note"]; ok {
+		t.Error("expected the field to be skipped")
+	}
+
+	if n, ok := reg.fieldNormalize["Title"]; !ok || n.column(`"title"`) != `unaccent(lower("title")) COLLATE "und-x-icu"` {
+		t.Errorf("expected the title to be normalized, got %+v", n)
+	}
+}
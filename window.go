@@ -0,0 +1,86 @@
+package liqu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expandWindow replaces the fields between braces in the expression of a window field by their columns
+func (l *Liqu) expandWindow(model, expression string) string {
+	reg := l.registry[model]
+
+	return expressionFieldRegex.ReplaceAllStringFunc(expression, func(s string) string {
+		field := s[1 : len(s)-1]
+		if _, ok := reg.fieldDatabase[field]; !ok || reg.fieldWindow[field] {
+			return s
+		}
+
+		return l.fieldColumn(model, field)
+	})
+}
+
+// windowFields returns the fields the window field is computed over
+func (l *Liqu) windowFields(model, field string) []string {
+	var (
+		reg    = l.registry[model]
+		fields = make([]string, 0)
+	)
+
+	for _, match := range expressionFieldRegex.FindAllStringSubmatch(reg.fieldExpression[field], -1) {
+		if _, ok := reg.fieldDatabase[match[1]]; ok && !reg.fieldWindow[match[1]] {
+			fields = appendUnique(fields, match[1])
+		}
+	}
+
+	return fields
+}
+
+// processWindow computes the field of the root with the window function of the defaults and selects it
+func (l *Liqu) processWindow(column, expression string) error {
+	model, field := l.splitColumn(column)
+
+	reg, ok := l.registry[model]
+	if !ok || reg.branch != l.tree {
+		return fmt.Errorf("invalid window field %s, window fields are only supported on the root", column)
+	}
+
+	if !strings.Contains(strings.ToUpper(expression), " OVER") {
+		return fmt.Errorf("invalid window field %s, the expression has no OVER clause", column)
+	}
+
+	if _, ok = reg.fieldDatabase[field]; !ok {
+		reg.fieldDatabase[field] = toSnakeCase(field)
+	}
+
+	reg.fieldExpression[field] = expression
+	reg.fieldWindow[field] = true
+
+	for _, match := range expressionFieldRegex.FindAllStringSubmatch(expression, -1) {
+		if _, ok = reg.fieldDatabase[match[1]]; !ok || reg.fieldWindow[match[1]] {
+			return fmt.Errorf("invalid window field %s, unknown field %s", column, match[1])
+		}
+	}
+
+	l.processSelect(model, field)
+
+	return nil
+}
+
+// processWindowWhere filters on a window field in the wrapping query, as window functions are computed after the
+// conditions of the query they are in. the rows are still filtered before they are paginated.
+func (l *Liqu) processWindowWhere(outerOperator Operator, model, field string, op Operator, val interface{}) error {
+	branch := l.registry[model].branch
+	if branch != l.tree || branch.anonymous {
+		return fmt.Errorf("invalid search field %s.%s, window fields can only be searched on a wrapped root", model, field)
+	}
+
+	// the wrapping query filters on the alias, so the field has to be selected
+	branch.referencedFields[field] = true
+
+	err := l.condition(branch.outerWhere, outerOperator, model, field, fmt.Sprintf(`"%s"."%s"`, branch.as, field), op, val)
+	if err != nil {
+		return fmt.Errorf("invalid search field %s.%s: %w", model, field, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package liqu
+
+import (
+	"context"
+	"testing"
+)
+
+type (
+	Player struct {
+		ID       int    `db:"id"`
+		TeamID   int    `db:"team_id"`
+		Score    int    `db:"score"`
+		Position int    `db:"-" liqu:"window:rank() OVER (ORDER BY {Score} DESC)"`
+		Name     string `db:"name"`
+	}
+
+	PlayerList struct {
+		Player Player
+	}
+)
+
+func (m *Player) Table() string {
+	return "player"
+}
+
+func (m *Player) PrimaryKeys() []string {
+	return []string{"ID"}
+}
+
+func TestWithWindow(t *testing.T) {
+	filters := &Filters{
+		Select:  "Player.Name,Player.Position,Player.TeamPosition",
+		Where:   "Player.Name|ILIKE|jo,Player.Position|<=|10",
+		OrderBy: "Player.Position|ASC",
+		Page:    3,
+		PerPage: 10,
+	}
+
+	def := NewDefaults().
+		Window("Player.TeamPosition", "row_number() OVER (PARTITION BY {TeamID} ORDER BY {Score} DESC)")
+
+	li := New(context.TODO(), filters).
+		WithDefaults(def).
+		WithoutTieBreaker()
+
+	err := li.FromSource(make([]PlayerList, 0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	sqlQuery, sqlParams := li.SQL()
+
+	expected := `SELECT coalesce(jsonb_agg(q),'[]') FROM ( SELECT count(*) OVER() AS TotalRows, to_jsonb( "Player" ) AS "Player" FROM ( SELECT (rank() OVER (ORDER BY "player"."score" DESC)) AS "Position", "player"."id" AS "ID", "player"."name" AS "Name", (row_number() OVER (PARTITION BY "player"."team_id" ORDER BY "player"."score" DESC)) AS "TeamPosition" FROM "player" WHERE "player"."name" ILIKE $1 GROUP BY "player"."score", "player"."id", "player"."name", "player"."team_id" ORDER BY (rank() OVER (ORDER BY "player"."score" DESC)) ASC) AS "Player" WHERE "Player"."Position" <= $2 ORDER BY "Position" ASC LIMIT 10 OFFSET 20 ) q`
+	if sqlQuery != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, sqlQuery)
+	}
+
+	if len(sqlParams) != 2 {
+		t.Errorf("expected 2 params, got %d", len(sqlParams))
+	}
+
+	li = New(context.TODO(), nil).
+		WithDefaults(NewDefaults().Window("Player.Total", "sum({Unknown}) OVER ()"))
+
+	if err = li.FromSource(make([]PlayerList, 0)); err == nil {
+		t.Error("expected an error on a window over an unknown field")
+	}
+
+	type TeamPlayers struct {
+		Project Project
+		Players []Player `related:"Players.TeamID=Project.ID" join:"left"`
+	}
+
+	li = New(context.TODO(), nil)
+	if err = li.FromSource(make([]TeamPlayers, 0)); err == nil || err.Error() != "[liqu] window field Players.Position is only supported on the root" {
+		t.Errorf("expected an error on a window field of a relation, got %v", err)
+	}
+}